@@ -0,0 +1,243 @@
+package wpgx
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
+	"github.com/pkg/errors"
+)
+
+// Batch queues operations to send them in one round trip
+//
+// Deal, Load and Save are queued like the Dealer ones. Each has its own collector or item
+//
+// Send sends all queued operations and routes the results. Use cooked queries with
+// arguments, otherwise an extra round trip is needed to describe them
+type Batch interface {
+	Deal(result Collector, query string, args ...interface{})
+	Load(item Shaper, query string, args ...interface{})
+	Save(item Shaper, key string, result Collector)
+	Send() error
+	SendContext(ctx context.Context) error
+}
+
+// BatchError holds errors of the queued operations in the queue order
+// Succeeded operations have nil errors
+type BatchError []error
+
+func (e BatchError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for i := range e {
+		if e[i] != nil {
+			msgs = append(msgs, "#"+strconv.Itoa(i)+": "+e[i].Error())
+		}
+	}
+	return "batch failed: " + strings.Join(msgs, "; ")
+}
+
+type batchOp struct {
+	query  string
+	args   []interface{}
+	result Collector
+	cols   []string
+	err    error
+}
+
+type batch struct {
+	c   *conn
+	t   *tx
+	ops []*batchOp
+}
+
+func (c *conn) Batch() Batch { return &batch{c: c} }
+func (t *tx) Batch() Batch   { return &batch{c: t.c, t: t} }
+
+func (b *batch) Deal(result Collector, query string, args ...interface{}) {
+	b.ops = append(b.ops, &batchOp{query: query, args: args, result: result})
+}
+
+func (b *batch) Load(item Shaper, query string, args ...interface{}) {
+	b.ops = append(b.ops, &batchOp{query: query, args: args, result: &oneItem{item: item}})
+}
+
+func (b *batch) Save(item Shaper, key string, result Collector) {
+	op := &batchOp{query: key, result: result}
+	b.ops = append(b.ops, op)
+
+	if b.c == nil {
+		op.err = ErrConnClosed
+		return
+	}
+
+	st, ok := b.c.stmts.get(key)
+	if !ok {
+		op.err = errors.New("unknown prepared query key: " + key)
+		return
+	}
+
+	op.cols = st.cols
+	op.args = make([]interface{}, len(st.cols))
+	model := item.Extrude()
+
+	for i := range st.cols {
+		op.args[i] = model.Translate(st.cols[i])
+	}
+}
+
+func (b *batch) Send() error {
+	if b.t != nil {
+		return b.SendContext(b.t.ctx)
+	}
+	return b.SendContext(context.Background())
+}
+
+func (b *batch) SendContext(ctx context.Context) (err error) {
+	const emsg = "sending batch"
+
+	if b.t != nil {
+		return b.t.send(ctx, b.ops)
+	}
+
+	var d Dealer
+
+	if err = b.c.ready(); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+
+	if d, err = b.c.NewDealerContext(ctx); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+	defer func() { d.Jail(err == nil) }()
+
+	return d.(*tx).send(ctx, b.ops)
+}
+
+// send queues all correct operations into one pgx batch and routes the results
+func (t *tx) send(ctx context.Context, ops []*batchOp) (err error) {
+	const emsg = "sending batch"
+
+	if err = t.ready(); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+
+	c := t.c
+	failed := false
+	errs := make(BatchError, len(ops))
+	queue := t.BeginBatch()
+
+	for i, op := range ops {
+		if errs[i] = op.err; op.err != nil {
+			failed = true
+			continue
+		}
+
+		var oids []pgtype.OID
+		var formats []int16
+
+		if oids, formats, err = t.statement(ctx, op.query, len(op.args) > 0); err != nil {
+			errs[i] = errors.Wrap(t.fail(ctx, t.query(err, op.query, nil)), "preparing statement")
+			failed = true
+			continue
+		}
+
+		queue.Queue(op.query, op.args, oids, formats)
+	}
+
+	if err = ctx.Err(); err != nil {
+		return errors.Wrap(t.fail(ctx, err), emsg)
+	}
+
+	if err = queue.Send(ctx, nil); err != nil {
+		return errors.Wrap(t.fail(ctx, err), emsg)
+	}
+
+	aborted := false
+
+	for i, op := range ops {
+		if errs[i] != nil {
+			continue
+		}
+
+		if aborted {
+			errs[i] = ErrBatchAborted
+			continue
+		}
+
+		var n int
+
+		finish := t.watch(op.query)
+
+		if op.result == nil {
+			var tag pgx.CommandTag
+
+			tag, errs[i] = queue.ExecResults()
+			n = int(tag.RowsAffected())
+		} else {
+			n, errs[i] = fetchResults(queue, op.result)
+		}
+
+		errs[i] = t.query(errs[i], op.query, op.args)
+		finish(n, errs[i])
+
+		if errs[i] == nil {
+			errs[i] = ctx.Err()
+		}
+
+		if errs[i] != nil {
+			failed = true
+			aborted = true
+			if op.cols != nil {
+				c.reserve(op.query, op.cols, op.args)
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		t.fail(ctx, ctx.Err())
+	}
+
+	if failed {
+		return errs
+	}
+
+	return errors.Wrap(queue.Close(), emsg)
+}
+
+// statement prepares the cooked query or describes the one with arguments, which was not cooked
+// The last one is described as the unnamed statement, so it is not in the registry and
+// doesn't evict the cooked ones. Its parameter types and result formats are queued with it
+func (t *tx) statement(ctx context.Context, query string, describe bool) ([]pgtype.OID, []int16, error) {
+	if _, ok := t.c.stmts.get(query); ok || !describe || unknown(query) != nil {
+		return nil, nil, t.prepare(ctx, query)
+	}
+
+	ps, err := t.PrepareEx(ctx, "", query, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	formats := make([]int16, len(ps.FieldDescriptions))
+	for i := range ps.FieldDescriptions {
+		formats[i] = ps.FieldDescriptions[i].FormatCode
+	}
+
+	return ps.ParameterOIDs, formats, nil
+}
+
+func fetchResults(queue *pgx.Batch, result Collector) (int, error) {
+	rows, err := queue.QueryResults()
+	if err != nil {
+		return 0, errors.Wrap(err, "selecting data")
+	}
+	defer rows.Close()
+
+	n, err := fetch(rows, result)
+	if err != nil {
+		return n, err
+	}
+
+	return n, errors.Wrap(rows.Err(), "checking result")
+}
@@ -0,0 +1,93 @@
+package wpgx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE batch_users (id serial PRIMARY KEY, name text not null);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE batch_users;`)
+		assert.NoError(t, err)
+	}()
+
+	sqlInsert, err := db.Cook(`INSERT INTO batch_users (name) VALUES ($1) RETURNING id;`, "name")
+	assert.NoError(t, err)
+
+	sqlSelect, err := db.Cook(`SELECT * FROM batch_users WHERE id = $1;`)
+	assert.NoError(t, err)
+
+	first := make(wpgx.Ints, 0, 1)
+	second := make(wpgx.Ints, 0, 1)
+
+	b := db.Batch()
+	b.Save(&user{Name: "first"}, sqlInsert, &first)
+	b.Save(&user{Name: "second"}, sqlInsert, &second)
+	b.Deal(nil, `UPDATE batch_users SET name = name || $1;`, "!")
+	err = b.Send()
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Ints{1}, first)
+	assert.Equal(t, wpgx.Ints{2}, second)
+
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+
+	u := new(user)
+	names := make(wpgx.Strings, 0, 2)
+
+	b = d.Batch()
+	b.Load(u, sqlSelect, 2)
+	b.Deal(&names, `SELECT name FROM batch_users ORDER BY id;`)
+	err = b.Send()
+	assert.NoError(t, err)
+	assert.Equal(t, &user{ID: 2, Name: "second!"}, u)
+	assert.Equal(t, wpgx.Strings{"first!", "second!"}, names)
+
+	b = d.Batch()
+	b.Deal(nil, `SELECT 1;`)
+	b.Save(new(user), "unknown", nil)
+	b.Save(new(user), sqlInsert, nil)
+	b.Deal(nil, `SELECT 2;`)
+	err = b.Send()
+	assert.Error(t, err)
+
+	errs, ok := errors.Cause(err).(wpgx.BatchError)
+	assert.True(t, ok)
+	assert.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.EqualError(t, errs[1], "unknown prepared query key: unknown")
+	assert.Error(t, errs[2])
+	assert.Equal(t, wpgx.ErrBatchAborted, errs[3])
+
+	d.Jail(false)
+
+	// Raw query with the text of the cooked one doesn't replace it
+	third := make(wpgx.Ints, 0, 1)
+
+	b = db.Batch()
+	b.Deal(&third, `INSERT INTO batch_users (name) VALUES ($1) RETURNING id;`, "third")
+	assert.NoError(t, b.Send())
+	assert.Len(t, third, 1)
+
+	fourth := make(wpgx.Ints, 0, 1)
+	assert.NoError(t, db.Save(&user{Name: "fourth"}, sqlInsert, &fourth))
+	assert.Len(t, fourth, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	b = db.Batch()
+	b.Deal(nil, `SELECT 1;`)
+	assert.Error(t, b.SendContext(ctx))
+}
@@ -0,0 +1,331 @@
+package wpgx
+
+import (
+	"container/list"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
+	"github.com/pkg/errors"
+)
+
+// CacheConfig describes the result cache of the cooked statements
+// Size is a limit of the cached data in bytes. Any notification of the channel clears the cache
+//
+// Only Deal and Fetch of the connector and read only dealers use the cache,
+// so rows of uncommitted writes are never cached
+type CacheConfig struct {
+	TTL     time.Duration
+	Size    int
+	Channel string
+}
+
+// cacheEntry has raw rows of the query, so they are decoded into any model again
+type cacheEntry struct {
+	key     string
+	info    *pgtype.ConnInfo
+	fields  []pgx.FieldDescription
+	rows    [][][]byte
+	size    int
+	gen     uint64
+	expires time.Time
+}
+
+// resultCache keeps rows of the statements, that are marked as cached
+// Least recently used entries are evicted over the size limit
+//
+// Generation is changed by each clear, so the rows, that were selected before it,
+// are not put after it
+type resultCache struct {
+	sync.Mutex
+	CacheConfig
+	keys  map[string]struct{}
+	order *list.List
+	items map[string]*list.Element
+	used  int
+	gen   uint64
+}
+
+func newResultCache(cfg CacheConfig) *resultCache {
+	return &resultCache{
+		CacheConfig: cfg,
+		keys:        make(map[string]struct{}),
+		order:       list.New(),
+		items:       make(map[string]*list.Element),
+	}
+}
+
+// mark enables caching of the statement
+func (r *resultCache) mark(key string) {
+	r.Lock()
+	defer r.Unlock()
+	r.keys[key] = struct{}{}
+}
+
+// key makes a cache key of the statement and arguments
+// It is empty, when the statement is not cached or arguments can't be dumped
+func (r *resultCache) key(query string, args []interface{}) string {
+	r.Lock()
+	_, ok := r.keys[query]
+	r.Unlock()
+
+	if !ok {
+		return ""
+	}
+
+	dump := make([]reserveArg, len(args))
+
+	for i := range args {
+		var err error
+		if dump[i], err = dumpArg("", args[i]); err != nil {
+			return ""
+		}
+	}
+
+	text, err := json.Marshal(dump)
+	if err != nil {
+		return ""
+	}
+
+	return query + "\x00" + string(text)
+}
+
+func (r *resultCache) get(key string) (*cacheEntry, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	el, ok := r.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+
+	if time.Now().After(entry.expires) {
+		r.drop(el)
+		return nil, false
+	}
+
+	r.order.MoveToFront(el)
+	return entry, true
+}
+
+// generation is the current generation, it is taken before the rows are selected
+func (r *resultCache) generation() uint64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.gen
+}
+
+func (r *resultCache) put(entry *cacheEntry) {
+	r.Lock()
+	defer r.Unlock()
+
+	if entry.size > r.Size || entry.gen != r.gen {
+		return
+	}
+
+	if el, ok := r.items[entry.key]; ok {
+		r.drop(el)
+	}
+
+	entry.expires = time.Now().Add(r.TTL)
+	r.items[entry.key] = r.order.PushFront(entry)
+	r.used += entry.size
+
+	for r.used > r.Size {
+		r.drop(r.order.Back())
+	}
+}
+
+func (r *resultCache) drop(el *list.Element) {
+	entry := r.order.Remove(el).(*cacheEntry)
+	delete(r.items, entry.key)
+	r.used -= entry.size
+}
+
+// clear drops all the entries, but the statements are still cached
+func (r *resultCache) clear() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.order.Init()
+	r.items = make(map[string]*list.Element)
+	r.used = 0
+	r.gen++
+}
+
+// invalidate clears the cache on each notification, until the subscription is closed
+func (r *resultCache) invalidate(sub Subscription) {
+	for range sub.Notifications() {
+		r.clear()
+	}
+}
+
+func (c *conn) Cache(key string) error {
+	if err := c.ready(); err != nil {
+		return errors.Wrap(err, "caching statement")
+	}
+
+	if c.cache == nil {
+		return errors.New("result cache is off")
+	}
+
+	if _, ok := c.stmts.get(key); !ok {
+		return errors.New("unknown prepared query key: " + key)
+	}
+
+	c.cache.mark(key)
+	return nil
+}
+
+// rawValue keeps a column value as is, nil is for NULL
+type rawValue []byte
+
+func (v *rawValue) DecodeText(ci *pgtype.ConnInfo, src []byte) error   { return v.decode(src) }
+func (v *rawValue) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error { return v.decode(src) }
+
+func (v *rawValue) decode(src []byte) error {
+	if src == nil {
+		*v = nil
+	} else {
+		*v = append(rawValue{}, src...)
+	}
+	return nil
+}
+
+// capture reads all the rows without decoding
+func capture(rows *pgx.Rows, info *pgtype.ConnInfo, key string, gen uint64) (*cacheEntry, error) {
+	fields := append([]pgx.FieldDescription(nil), rows.FieldDescriptions()...)
+	entry := &cacheEntry{key: key, info: info, fields: fields, size: len(key), gen: gen}
+
+	values := make([]rawValue, len(fields))
+	places := make([]interface{}, len(fields))
+	for i := range values {
+		places[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(places...); err != nil {
+			return nil, errors.Wrap(err, "scanning data row")
+		}
+
+		row := make([][]byte, len(values))
+		for i := range values {
+			row[i] = values[i]
+			entry.size += len(values[i]) + 24
+		}
+
+		entry.rows = append(entry.rows, row)
+	}
+
+	return entry, nil
+}
+
+// feed loads the rows into the collector the same way as fetch does
+func (e *cacheEntry) feed(result Collector) (n int, err error) {
+	places := make([]interface{}, len(e.fields))
+
+	for _, row := range e.rows {
+		item := result.NewItem()
+
+		if item == nil {
+			break
+		}
+
+		model := item.Extrude()
+
+		for i := range e.fields {
+			places[i] = model.Translate(e.fields[i].Name)
+		}
+
+		for i := range places {
+			if err = e.decode(i, row[i], places[i]); err != nil {
+				return n, errors.Wrap(err, "scanning data row")
+			}
+		}
+
+		if err = item.Receive(model); err != nil {
+			return n, errors.Wrap(err, "receiving model")
+		}
+
+		if err = result.Collect(item); err != nil {
+			return n, errors.Wrap(err, "collecting item")
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+// decode is the same as pgx.Rows.Scan for one column
+// Source is copied, because decoders may keep it
+func (e *cacheEntry) decode(col int, src []byte, dest interface{}) (err error) {
+	if dest == nil {
+		return nil
+	}
+
+	if src != nil {
+		src = append([]byte{}, src...)
+	}
+
+	fd := &e.fields[col]
+
+	defer func() {
+		if err != nil {
+			err = errors.Errorf("can't scan into dest[%d]: %v", col, err)
+		}
+	}()
+
+	if d, ok := dest.(pgtype.BinaryDecoder); ok && fd.FormatCode == pgx.BinaryFormatCode {
+		return d.DecodeBinary(e.info, src)
+	}
+
+	if d, ok := dest.(pgtype.TextDecoder); ok && fd.FormatCode == pgx.TextFormatCode {
+		return d.DecodeText(e.info, src)
+	}
+
+	dt, ok := e.info.DataTypeForOID(fd.DataType)
+	if !ok {
+		return errors.Errorf("unknown oid: %v, name: %s", fd.DataType, fd.Name)
+	}
+
+	// Data type value of the connection is not safe for concurrent use
+	value := reflect.New(reflect.ValueOf(dt.Value).Elem().Type()).Interface().(pgtype.Value)
+
+	switch fd.FormatCode {
+	case pgx.TextFormatCode:
+		d, ok := value.(pgtype.TextDecoder)
+		if !ok {
+			return errors.Errorf("%T is not a pgtype.TextDecoder", value)
+		}
+		err = d.DecodeText(e.info, src)
+	case pgx.BinaryFormatCode:
+		d, ok := value.(pgtype.BinaryDecoder)
+		if !ok {
+			return errors.Errorf("%T is not a pgtype.BinaryDecoder", value)
+		}
+		err = d.DecodeBinary(e.info, src)
+	default:
+		return errors.Errorf("unknown format code: %v", fd.FormatCode)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if scanner, ok := dest.(sql.Scanner); ok {
+		var v interface{}
+		if v, err = pgtype.DatabaseSQLValue(e.info, value); err != nil {
+			return err
+		}
+		return scanner.Scan(v)
+	}
+
+	return value.AssignTo(dest)
+}
@@ -0,0 +1,112 @@
+package wpgx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	db, err := wpgx.Connect(connStr, wpgx.ResultCache(time.Minute, 1<<20, "cache_roles"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE cache_roles (id int PRIMARY KEY, name text);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE cache_roles;`)
+		assert.NoError(t, err)
+	}()
+
+	err = db.Deal(nil, `INSERT INTO cache_roles VALUES (1, 'admin'), (2, 'user'), (3, NULL);`)
+	assert.NoError(t, err)
+
+	type role struct {
+		ID   int     `db:"id"`
+		Name *string `db:"name"`
+	}
+
+	sqlSelect, err := db.Cook(`SELECT * FROM cache_roles WHERE id >= $1 ORDER BY id;`)
+	assert.NoError(t, err)
+
+	assert.EqualError(t, db.Cache("unknown"), "unknown prepared query key: unknown")
+	assert.NoError(t, db.Cache(sqlSelect))
+
+	var roles []role
+	assert.NoError(t, db.Deal(wpgx.Slice(&roles), sqlSelect, 2))
+	assert.Len(t, roles, 2)
+	assert.Equal(t, "user", *roles[0].Name)
+	assert.Nil(t, roles[1].Name)
+
+	// Changes are not seen until the notification
+	err = db.Deal(nil, `UPDATE cache_roles SET name = 'guest' WHERE id = 2;`)
+	assert.NoError(t, err)
+
+	roles = nil
+	assert.NoError(t, db.Deal(wpgx.Slice(&roles), sqlSelect, 2))
+	assert.Len(t, roles, 2)
+	assert.Equal(t, "user", *roles[0].Name)
+
+	// Another argument is another entry
+	roles = nil
+	assert.NoError(t, db.Deal(wpgx.Slice(&roles), sqlSelect, 1))
+	assert.Len(t, roles, 3)
+	assert.Equal(t, "guest", *roles[1].Name)
+
+	// Models are filled with copies
+	*roles[1].Name = "changed"
+
+	roles = nil
+	assert.NoError(t, db.Deal(wpgx.Slice(&roles), sqlSelect, 1))
+	assert.Equal(t, "guest", *roles[1].Name)
+
+	var first role
+	assert.NoError(t, db.Fetch(wpgx.Shape(&first), sqlSelect, 3))
+	assert.Equal(t, 3, first.ID)
+
+	assert.NoError(t, db.Notify("cache_roles", ""))
+
+	assert.Eventually(t, func() bool {
+		roles = nil
+		assert.NoError(t, db.Deal(wpgx.Slice(&roles), sqlSelect, 2))
+		return len(roles) == 2 && *roles[0].Name == "guest"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	err = db.Deal(nil, `UPDATE cache_roles SET name = 'owner' WHERE id = 1;`)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Notify("cache_roles", ""))
+
+	assert.Eventually(t, func() bool {
+		roles = nil
+		assert.NoError(t, db.Deal(wpgx.Slice(&roles), sqlSelect, 1))
+		return len(roles) == 3 && *roles[0].Name == "owner"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Dealer, that writes, neither loads nor puts the cached rows
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+
+	err = d.Deal(nil, `UPDATE cache_roles SET name = 'nobody' WHERE id = 1;`)
+	assert.NoError(t, err)
+
+	roles = nil
+	assert.NoError(t, d.Deal(wpgx.Slice(&roles), sqlSelect, 1))
+	assert.Equal(t, "nobody", *roles[0].Name)
+	assert.NoError(t, d.Jail(false))
+
+	roles = nil
+	assert.NoError(t, db.Deal(wpgx.Slice(&roles), sqlSelect, 1))
+	assert.Equal(t, "owner", *roles[0].Name)
+}
+
+func TestCacheOff(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlSelect, err := db.Cook(`SELECT 1 AS val;`)
+	assert.NoError(t, err)
+	assert.EqualError(t, db.Cache(sqlSelect), "result cache is off")
+}
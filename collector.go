@@ -5,9 +5,21 @@ import "github.com/pkg/errors"
 // ErrConnClosed occurs when an attemtp to use closed conncection
 var ErrConnClosed = errors.New("connection is closed")
 
+// ErrNestedDealer occurs when a dealer is used while its nested dealer is open
+var ErrNestedDealer = errors.New("nested dealer is still open")
+
+// ErrBatchAborted occurs when batch operation is skipped after an error of the previous one
+var ErrBatchAborted = errors.New("batch is aborted")
+
 // ErrUnknownType occurs when collector meets unknown shaper type
 var ErrUnknownType = errors.New("unknown shaper type")
 
+// ErrNotFound occurs when Fetch gets no rows
+var ErrNotFound = errors.New("item is not found")
+
+// ErrTooManyRows occurs when Fetch gets more than one row
+var ErrTooManyRows = errors.New("too many rows")
+
 // Collector is a generic collection. It can use lists, maps or channels inside
 //
 // NewItem prepares a translator entity for future loads
@@ -18,6 +30,13 @@ type Collector interface {
 	Collect(item Shaper) error
 }
 
+// Provider is a source of items for bulk operations
+//
+// Next returns the next item or nil, when there are no more items
+type Provider interface {
+	Next() (Shaper, error)
+}
+
 // Shaper helps to make database model from business model and vice versa
 //
 // Extrude makes a database model from business data
@@ -3,6 +3,7 @@ package wpgx
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/pkg/errors"
@@ -11,9 +12,40 @@ import (
 // Config is just a pgx.ConnPoolConfig with some extra options
 type Config struct {
 	ReservePath string
+	Statements  int
+	Observer    Observer
+	Tracer      Tracer
+	Logger      Logger
+	SlowQuery   time.Duration
+	Explain     bool
+	Cache       CacheConfig
+	Replicas    []string
+	MaxLag      time.Duration
+	LagCheck    time.Duration
+	Tx          TxConfig
 	pgx.ConnPoolConfig
 }
 
+// TxConfig describes how dealers begin transactions and how InTx retries them
+type TxConfig struct {
+	pgx.TxOptions
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Consistent bool
+}
+
+func newTxConfig(base TxConfig, options []func(*TxConfig) error) (*TxConfig, error) {
+	cfg := &base
+
+	for i := range options {
+		if err := options[i](cfg); err != nil {
+			return nil, errors.Wrap(err, "applying transaction options")
+		}
+	}
+	return cfg, nil
+}
+
 // PoolSize is a config helper to set pgx.ConnPoolConfig.MaxConnections field
 func PoolSize(size int) func(*Config) error {
 	return func(cfg *Config) error {
@@ -26,19 +58,37 @@ func PoolSize(size int) func(*Config) error {
 	}
 }
 
-// LogLevel is a config helper for use glog as logger
-// godoc: https://godoc.org/github.com/golang/glog
+// LogLevel is a config helper to set level of pgx and wpgx records
+// They are written to glog, unless another logger is set
 func LogLevel(lvl int) func(*Config) error {
 	return func(cfg *Config) error {
 		if lvl < 0 {
 			lvl = pgx.LogLevelNone
 		}
-		cfg.ConnPoolConfig.ConnConfig.Logger = new(logger)
+		if cfg.Logger == nil {
+			cfg.ConnPoolConfig.ConnConfig.Logger = &logger{Glog()}
+		}
 		cfg.ConnPoolConfig.ConnConfig.LogLevel = pgx.LogLevel(lvl)
 		return nil
 	}
 }
 
+// Logging is a config helper to set the logger of pgx and wpgx records, like Slog or Nop
+// The level is warning by default, otherwise pgx would log every query at debug level
+func Logging(l Logger) func(*Config) error {
+	return func(cfg *Config) error {
+		if l == nil {
+			return errors.New("no logger")
+		}
+		if cfg.ConnPoolConfig.ConnConfig.LogLevel == 0 {
+			cfg.ConnPoolConfig.ConnConfig.LogLevel = pgx.LogLevelWarn
+		}
+		cfg.Logger = l
+		cfg.ConnPoolConfig.ConnConfig.Logger = &logger{l}
+		return nil
+	}
+}
+
 // ReservePath is a config helper to set catalog for saving
 // prepared sql files and uncommitted object data as json files
 func ReservePath(possible string) func(*Config) error {
@@ -67,3 +117,169 @@ func ReservePath(possible string) func(*Config) error {
 		return
 	}
 }
+
+// StatementLimit is a config helper to set how many cooked statements are kept
+// Least recently used ones are evicted over the limit. Zero means no limit
+func StatementLimit(limit int) func(*Config) error {
+	return func(cfg *Config) error {
+		if limit < 0 {
+			return errors.New("invalid statement limit")
+		}
+		cfg.Statements = limit
+		return nil
+	}
+}
+
+// Observe is a config helper to set the observer of dealers and queries, like Metrics
+func Observe(o Observer) func(*Config) error {
+	return func(cfg *Config) error {
+		cfg.Observer = o
+		return nil
+	}
+}
+
+// Trace is a config helper to set the tracer of dealers and queries, like Recorder
+func Trace(t Tracer) func(*Config) error {
+	return func(cfg *Config) error {
+		cfg.Tracer = t
+		return nil
+	}
+}
+
+// SlowQuery is a config helper to log Deal, Load and Save calls, that took longer than
+// the threshold. With explain flag the query plan is captured on another connection
+func SlowQuery(threshold time.Duration, explain bool) func(*Config) error {
+	return func(cfg *Config) error {
+		if threshold <= 0 {
+			return errors.New("invalid slow query threshold")
+		}
+		cfg.SlowQuery = threshold
+		cfg.Explain = explain
+		return nil
+	}
+}
+
+// ResultCache is a config helper to enable the result cache of the cooked statements,
+// marked with Connector.Cache. Entries live for ttl and take up to size bytes together
+// Any notification of the channel clears the cache. Empty channel means no invalidation
+func ResultCache(ttl time.Duration, size int, channel string) func(*Config) error {
+	return func(cfg *Config) error {
+		if ttl <= 0 {
+			return errors.New("invalid cache ttl")
+		}
+		if size <= 0 {
+			return errors.New("invalid cache size")
+		}
+		cfg.Cache = CacheConfig{TTL: ttl, Size: size, Channel: channel}
+		return nil
+	}
+}
+
+// Replica is a config helper to add a standby server for read only dealers
+func Replica(uri string) func(*Config) error {
+	return func(cfg *Config) error {
+		if _, err := pgx.ParseConnectionString(uri); err != nil {
+			return errors.Wrap(err, "parsing replica connection string")
+		}
+		cfg.Replicas = append(cfg.Replicas, uri)
+		return nil
+	}
+}
+
+// ReplicaLag is a config helper to set how often replay lag of replicas is checked
+// Replica is out of rotation, while its lag is over max
+func ReplicaLag(max, interval time.Duration) func(*Config) error {
+	return func(cfg *Config) error {
+		if max <= 0 || interval <= 0 {
+			return errors.New("invalid replica lag")
+		}
+		cfg.MaxLag = max
+		cfg.LagCheck = interval
+		return nil
+	}
+}
+
+// TxDefaults is a config helper to set transaction options for all dealers
+// They can be overridden for a single dealer with the same helpers
+func TxDefaults(options ...func(*TxConfig) error) func(*Config) error {
+	return func(cfg *Config) error {
+		tx, err := newTxConfig(cfg.Tx, options)
+		if err != nil {
+			return err
+		}
+		cfg.Tx = *tx
+		return nil
+	}
+}
+
+// IsoLevel is a transaction config helper to set isolation level
+func IsoLevel(lvl pgx.TxIsoLevel) func(*TxConfig) error {
+	return func(cfg *TxConfig) error {
+		switch lvl {
+		case "", pgx.ReadUncommitted, pgx.ReadCommitted, pgx.RepeatableRead, pgx.Serializable:
+			cfg.IsoLevel = lvl
+			return nil
+		}
+		return errors.New("unknown isolation level: " + string(lvl))
+	}
+}
+
+// ReadOnly is a transaction config helper to set read only or read write access mode
+func ReadOnly(on bool) func(*TxConfig) error {
+	return func(cfg *TxConfig) error {
+		if on {
+			cfg.AccessMode = pgx.ReadOnly
+		} else {
+			cfg.AccessMode = pgx.ReadWrite
+		}
+		return nil
+	}
+}
+
+// Deferrable is a transaction config helper to set deferrable mode
+// It has an effect only for serializable read only transactions
+func Deferrable(on bool) func(*TxConfig) error {
+	return func(cfg *TxConfig) error {
+		if on {
+			cfg.DeferrableMode = pgx.Deferrable
+		} else {
+			cfg.DeferrableMode = pgx.NotDeferrable
+		}
+		return nil
+	}
+}
+
+// ReadYourWrites is a transaction config helper to make read only dealer see all changes,
+// committed before it began. It waits, until the replica replays the primary position,
+// and goes to the primary, when the replica is too far behind
+func ReadYourWrites(on bool) func(*TxConfig) error {
+	return func(cfg *TxConfig) error {
+		cfg.Consistent = on
+		return nil
+	}
+}
+
+// Retries is a transaction config helper to set how many times callback is retried
+// after serialization failures and deadlocks
+func Retries(count int) func(*TxConfig) error {
+	return func(cfg *TxConfig) error {
+		if count < 0 {
+			count = 0
+		}
+		cfg.Retries = count
+		return nil
+	}
+}
+
+// Backoff is a transaction config helper to set delays between retries
+// Delay starts from min and doubles with each retry until it reaches max
+func Backoff(min, max time.Duration) func(*TxConfig) error {
+	return func(cfg *TxConfig) error {
+		if min <= 0 || max < min {
+			return errors.New("invalid backoff delays")
+		}
+		cfg.Backoff = min
+		cfg.MaxBackoff = max
+		return nil
+	}
+}
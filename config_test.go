@@ -3,6 +3,7 @@ package wpgx_test
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/shestakovda/wpgx"
@@ -39,4 +40,82 @@ func TestConfig(t *testing.T) {
 
 	err = wpgx.ReservePath("./config_test.go")(cfg)
 	assert.EqualError(t, err, "reserve path is not a directory")
+
+	assert.NoError(t, wpgx.StatementLimit(0)(cfg))
+	assert.Equal(t, 0, cfg.Statements)
+
+	assert.NoError(t, wpgx.StatementLimit(64)(cfg))
+	assert.Equal(t, 64, cfg.Statements)
+
+	err = wpgx.StatementLimit(-1)(cfg)
+	assert.EqualError(t, err, "invalid statement limit")
+	assert.Equal(t, 64, cfg.Statements)
+
+	assert.NoError(t, wpgx.Logging(wpgx.Nop())(cfg))
+	assert.Equal(t, wpgx.Nop(), cfg.Logger)
+	assert.NotNil(t, cfg.ConnPoolConfig.ConnConfig.Logger)
+	assert.Equal(t, pgx.LogLevel(pgx.LogLevelInfo), cfg.ConnPoolConfig.ConnConfig.LogLevel)
+
+	other := new(wpgx.Config)
+	assert.NoError(t, wpgx.Logging(wpgx.Nop())(other))
+	assert.Equal(t, pgx.LogLevel(pgx.LogLevelWarn), other.ConnPoolConfig.ConnConfig.LogLevel)
+
+	err = wpgx.Logging(nil)(cfg)
+	assert.EqualError(t, err, "no logger")
+
+	assert.NoError(t, wpgx.SlowQuery(time.Second, true)(cfg))
+	assert.Equal(t, time.Second, cfg.SlowQuery)
+	assert.True(t, cfg.Explain)
+
+	err = wpgx.SlowQuery(0, false)(cfg)
+	assert.EqualError(t, err, "invalid slow query threshold")
+	assert.Equal(t, time.Second, cfg.SlowQuery)
+
+	assert.NoError(t, wpgx.ResultCache(time.Minute, 1024, "roles")(cfg))
+	assert.Equal(t, wpgx.CacheConfig{TTL: time.Minute, Size: 1024, Channel: "roles"}, cfg.Cache)
+
+	err = wpgx.ResultCache(0, 1024, "")(cfg)
+	assert.EqualError(t, err, "invalid cache ttl")
+
+	err = wpgx.ResultCache(time.Minute, 0, "")(cfg)
+	assert.EqualError(t, err, "invalid cache size")
+
+	assert.NoError(t, wpgx.Replica("postgres://user@replica1/db")(cfg))
+	assert.Equal(t, []string{"postgres://user@replica1/db"}, cfg.Replicas)
+
+	err = wpgx.Replica("postgres://user@replica2:port/db")(cfg)
+	assert.Error(t, err)
+	assert.Len(t, cfg.Replicas, 1)
+
+	assert.NoError(t, wpgx.ReplicaLag(time.Second, 100*time.Millisecond)(cfg))
+	assert.Equal(t, time.Second, cfg.MaxLag)
+	assert.Equal(t, 100*time.Millisecond, cfg.LagCheck)
+
+	err = wpgx.ReplicaLag(0, time.Second)(cfg)
+	assert.EqualError(t, err, "invalid replica lag")
+
+	metrics := wpgx.NewMetrics()
+	assert.NoError(t, wpgx.Observe(metrics)(cfg))
+	assert.Equal(t, metrics, cfg.Observer)
+
+	err = wpgx.TxDefaults(wpgx.IsoLevel(pgx.Serializable), wpgx.ReadOnly(true), wpgx.Deferrable(true))(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, pgx.TxOptions{
+		IsoLevel:       pgx.Serializable,
+		AccessMode:     pgx.ReadOnly,
+		DeferrableMode: pgx.Deferrable,
+	}, cfg.Tx.TxOptions)
+
+	err = wpgx.TxDefaults(wpgx.ReadOnly(false), wpgx.Deferrable(false), wpgx.Retries(-1))(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, pgx.ReadWrite, cfg.Tx.AccessMode)
+	assert.Equal(t, pgx.NotDeferrable, cfg.Tx.DeferrableMode)
+	assert.Equal(t, 0, cfg.Tx.Retries)
+
+	assert.NoError(t, wpgx.TxDefaults(wpgx.ReadYourWrites(true))(cfg))
+	assert.True(t, cfg.Tx.Consistent)
+
+	err = wpgx.TxDefaults(wpgx.IsoLevel("chaos"))(cfg)
+	assert.EqualError(t, err, "applying transaction options: unknown isolation level: chaos")
+	assert.Equal(t, pgx.Serializable, cfg.Tx.IsoLevel)
 }
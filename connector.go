@@ -1,11 +1,9 @@
 package wpgx
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
-	"io/ioutil"
-	"path/filepath"
+	"context"
 	"sync"
+	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/pkg/errors"
@@ -15,23 +13,59 @@ import (
 // As a Dealer it can execute queries in a default transaction
 //
 // NewDealer spawns new dealer on the street. It needs to be jailed (closed)
+// Options override transaction defaults from the config
 //
-// Prepare saves query for further execution
+// NewDealerContext spawns new dealer with a context. It is used in methods without one
+//
+// Cook saves query for further execution. It is prepared on each connection before first use
+// Least recently used statements are evicted over the config limit
+// Uncook forgets the statement and deallocates it on all connections
+//
+// InTx runs callback in a new dealer. It commits when callback succeeds and rolls back
+// on error or panic. Whole callback is retried on serialization failures and deadlocks
+//
+// Build generates insert, update, delete and upsert statements of the table and cooks them
+//
+// Pager makes a keyset pager of the query, ordered by the key columns
+//
+// Cache enables the result cache of the cooked statement. Deal of the connector or a read only
+// dealer loads its rows from the cache, until they expire or a notification of the config channel comes
+//
+// Listen subscribes to the channel. It uses a dedicated connection outside the pool
 //
 // Close closes all free dealers with rollback
 type Connector interface {
 	Dealer
-	NewDealer() (Dealer, error)
+	NewDealer(options ...func(*TxConfig) error) (Dealer, error)
+	NewDealerContext(ctx context.Context, options ...func(*TxConfig) error) (Dealer, error)
+	InTx(fn func(Dealer) error, options ...func(*TxConfig) error) error
+	InTxContext(ctx context.Context, fn func(Dealer) error, options ...func(*TxConfig) error) error
+	Build(table Table) (Crud, error)
+	BuildContext(ctx context.Context, table Table) (Crud, error)
+	Pager(query string, size int, keys ...string) (*Pager, error)
+	PagerContext(ctx context.Context, query string, size int, keys ...string) (*Pager, error)
+	Cache(key string) error
+	Listen(channel string) (Subscription, error)
 	Close()
 }
 
 // Connect method initialize a new connection pool with uri in a connection string format
 // Reserve path is for saving args of failed queries. Useful for debug or data restore
+// Replicas, added with the Replica helper, serve read only dealers
 func Connect(uri string, options ...func(*Config) error) (Connector, error) {
 	var err error
 
 	c := new(conn)
-	cfg := new(Config)
+	cfg := &Config{
+		Statements: 1024,
+		MaxLag:     10 * time.Second,
+		LagCheck:   time.Second,
+		Tx: TxConfig{
+			Retries:    3,
+			Backoff:    10 * time.Millisecond,
+			MaxBackoff: time.Second,
+		},
+	}
 
 	if cfg.ConnPoolConfig.ConnConfig, err = pgx.ParseConnectionString(uri); err != nil {
 		return nil, errors.Wrap(err, "parsing connection string")
@@ -47,16 +81,69 @@ func Connect(uri string, options ...func(*Config) error) (Connector, error) {
 		return nil, errors.Wrap(err, "creating connection pool")
 	}
 
-	c.statements = make(map[string][]string, 128)
+	if len(cfg.Replicas) > 0 {
+		if c.replicas, err = newReplicas(cfg); err != nil {
+			c.pool.Close()
+			return nil, err
+		}
+	}
+
+	c.stmts = newRegistry(cfg.Statements)
+	c.subscriptions = make(map[*subscription]struct{})
+	c.connConfig = cfg.ConnPoolConfig.ConnConfig
 	c.reservePath = cfg.ReservePath
+	c.observer = cfg.Observer
+	c.tracer = cfg.Tracer
+	c.logger = cfg.Logger
+	c.logLevel = cfg.ConnPoolConfig.ConnConfig.LogLevel
+
+	if c.logLevel == 0 {
+		c.logLevel = pgx.LogLevelWarn
+	}
+	c.slowQuery = cfg.SlowQuery
+	c.explain = cfg.Explain
+	c.tx = cfg.Tx
+
+	if c.logger == nil {
+		c.logger = Glog()
+	}
+
+	if cfg.Cache.Size > 0 {
+		c.cache = newResultCache(cfg.Cache)
+	}
+
+	// Subscription is closed with the connector, so the cache stops listening
+	if c.cache != nil && cfg.Cache.Channel != "" {
+		var sub Subscription
+
+		if sub, err = c.Listen(cfg.Cache.Channel); err != nil {
+			c.Close()
+			return nil, errors.Wrap(err, "invalidating result cache")
+		}
+
+		go c.cache.invalidate(sub)
+	}
+
 	return c, nil
 }
 
 type conn struct {
 	sync.RWMutex
 	pool        *pgx.ConnPool
-	statements  map[string][]string
+	replicas    *replicas
+	stmts       *registry
 	reservePath string
+	observer    Observer
+	tracer      Tracer
+	logger      Logger
+	logLevel    pgx.LogLevel
+	slowQuery   time.Duration
+	explain     bool
+	cache       *resultCache
+	tx          TxConfig
+
+	connConfig    pgx.ConnConfig
+	subscriptions map[*subscription]struct{}
 }
 
 func (c *conn) ready() error {
@@ -66,83 +153,258 @@ func (c *conn) ready() error {
 	return nil
 }
 
-func (c *conn) Cook(text string, cols ...string) (key string, err error) {
+func (c *conn) Cook(text string, cols ...string) (string, error) {
+	return c.CookContext(context.Background(), text, cols...)
+}
+
+func (c *conn) NewDealer(options ...func(*TxConfig) error) (Dealer, error) {
+	return c.NewDealerContext(context.Background(), options...)
+}
+
+func (c *conn) Deal(result Collector, query string, args ...interface{}) error {
+	return c.DealContext(context.Background(), result, query, args...)
+}
+
+func (c *conn) Load(item Shaper, query string, args ...interface{}) error {
+	return c.LoadContext(context.Background(), item, query, args...)
+}
+
+func (c *conn) Fetch(item Shaper, query string, args ...interface{}) error {
+	return c.FetchContext(context.Background(), item, query, args...)
+}
+
+func (c *conn) Save(item Shaper, key string, result Collector) error {
+	return c.SaveContext(context.Background(), item, key, result)
+}
+
+func (c *conn) Nest() (Dealer, error) {
+	return c.NewDealer()
+}
+
+func (c *conn) Mode() pgx.TxOptions { return c.tx.TxOptions }
+
+func (c *conn) Jail(commit bool) error { return nil }
+
+func (c *conn) Uncook(key string) error {
+	return c.UncookContext(context.Background(), key)
+}
+
+func (c *conn) CookContext(ctx context.Context, text string, cols ...string) (key string, err error) {
+	var d Dealer
 	const emsg = "preparing statement"
 
-	if err = c.ready(); err != nil {
+	if d, err = c.NewDealerContext(ctx); err != nil {
 		return "", errors.Wrap(err, emsg)
 	}
+	defer func() { d.Jail(err == nil) }()
 
-	sum := sha1.Sum([]byte(text))
-	key = hex.EncodeToString(sum[:])
+	return d.CookContext(ctx, text, cols...)
+}
 
-	if _, err = c.pool.Prepare(key, text); err != nil {
-		return "", errors.Wrap(err, emsg)
+func (c *conn) UncookContext(ctx context.Context, key string) (err error) {
+	var d Dealer
+	const emsg = "deallocating statement"
+
+	if d, err = c.NewDealerContext(ctx); err != nil {
+		return errors.Wrap(err, emsg)
 	}
+	defer func() { d.Jail(err == nil) }()
 
-	c.Lock()
-	c.statements[key] = cols
-	c.Unlock()
+	return d.UncookContext(ctx, key)
+}
 
-	if c.reservePath == "" {
-		return
+func (c *conn) NewDealerContext(ctx context.Context, options ...func(*TxConfig) error) (Dealer, error) {
+	const emsg = "creating dealer"
+
+	cfg, err := c.txConfig(ctx, options)
+	if err != nil {
+		return nil, errors.Wrap(err, emsg)
 	}
 
-	path := filepath.Join(c.reservePath, key+".pgsql")
-	err = ioutil.WriteFile(path, []byte(text), 0755)
-	return key, errors.Wrap(err, emsg)
+	d, err := c.begin(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
 }
 
-func (c *conn) NewDealer() (Dealer, error) {
-	var err error
+// single makes a dealer of one call, it is allowed to use the result cache
+func (c *conn) single(ctx context.Context) (Dealer, error) {
+	cfg, err := c.txConfig(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating dealer")
+	}
+
+	d, err := c.begin(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	d.single = true
+	return d, nil
+}
+
+// txConfig applies dealer options over the defaults and the read only mark of the context
+func (c *conn) txConfig(ctx context.Context, options []func(*TxConfig) error) (*TxConfig, error) {
+	base := c.tx
+	if isReadOnly(ctx) {
+		base.AccessMode = pgx.ReadOnly
+	}
+	return newTxConfig(base, options)
+}
+
+func (c *conn) begin(ctx context.Context, cfg *TxConfig) (d *tx, err error) {
 	const emsg = "creating dealer"
 
-	if err := c.ready(); err != nil {
+	if err = c.ready(); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	mode := cfg.TxOptions
+	d = &tx{c: c, ctx: ctx, mode: mode}
+
+	if c.cache != nil {
+		d.gen = c.cache.generation()
+	}
+
+	if d.pool, err = c.route(ctx, cfg); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	// Connection is acquired explicitly, so the dealer knows it and the pool wait time
+	for {
+		wait := time.Now()
+
+		if d.cn, err = d.pool.AcquireEx(ctx); err != nil {
+			return nil, errors.Wrap(err, emsg)
+		}
+
+		c.observe(Event{Kind: EventAcquire, Duration: time.Since(wait)})
+
+		if d.Tx, err = d.cn.BeginEx(ctx, &mode); err == nil {
+			break
+		}
+
+		// Dead connection is replaced by the pool, so just try again like pgx does
+		alive := d.cn.IsAlive()
+		d.pool.Release(d.cn)
+
+		if alive || ctx.Err() != nil {
+			return nil, errors.Wrap(err, emsg)
+		}
+	}
+
+	if err = c.started(ctx, d); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	return d, nil
+}
+
+// beginOn makes a dealer on the connection, that is acquired already, e.g. the locked one
+// The connection is not released, when the dealer is jailed
+func (c *conn) beginOn(ctx context.Context, cn *pgx.Conn, cfg *TxConfig) (d *tx, err error) {
+	const emsg = "creating dealer"
+
+	if err = c.ready(); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	mode := cfg.TxOptions
+	d = &tx{c: c, cn: cn, ctx: ctx, mode: mode}
+
+	if c.cache != nil {
+		d.gen = c.cache.generation()
+	}
+
+	if d.Tx, err = cn.BeginEx(ctx, &mode); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	if err = c.started(ctx, d); err != nil {
 		return nil, errors.Wrap(err, emsg)
 	}
 
-	d := &tx{c: c}
-	d.Tx, err = c.pool.Begin()
-	return d, errors.Wrap(err, emsg)
+	return d, nil
+}
+
+// started observes and traces the begun transaction and deallocates the stale statements
+func (c *conn) started(ctx context.Context, d *tx) (err error) {
+	d.start = time.Now()
+	c.observe(Event{Kind: EventBegin})
+
+	if c.tracer != nil {
+		d.tctx, d.span = c.tracer.Start(ctx, "wpgx.transaction")
+		d.span.SetAttribute("db.system", "postgresql")
+	}
+
+	// Statements, evicted while the connection was busy or idle
+	for _, name := range c.stmts.drain(d.cn) {
+		if err = d.cn.Deallocate(name); err != nil {
+			d.rollback()
+			d.done(false, err)
+			d.close()
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (c *conn) Deal(result Collector, query string, args ...interface{}) (err error) {
+func (c *conn) DealContext(ctx context.Context, result Collector, query string, args ...interface{}) (err error) {
 	var d Dealer
 	const emsg = "executing query"
 
-	if d, err = c.NewDealer(); err != nil {
+	if d, err = c.single(ctx); err != nil {
 		return errors.Wrap(err, emsg)
 	}
 	defer func() { d.Jail(err == nil) }()
 
-	return d.Deal(result, query, args...)
+	return d.DealContext(ctx, result, query, args...)
 }
 
-func (c *conn) Load(item Shaper, query string, args ...interface{}) (err error) {
+func (c *conn) LoadContext(ctx context.Context, item Shaper, query string, args ...interface{}) (err error) {
 	var d Dealer
 	const emsg = "loading item"
 
-	if d, err = c.NewDealer(); err != nil {
+	if d, err = c.NewDealerContext(ctx); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+	defer func() { d.Jail(err == nil) }()
+
+	return d.LoadContext(ctx, item, query, args...)
+}
+
+func (c *conn) FetchContext(ctx context.Context, item Shaper, query string, args ...interface{}) (err error) {
+	var d Dealer
+	const emsg = "fetching item"
+
+	if d, err = c.single(ctx); err != nil {
 		return errors.Wrap(err, emsg)
 	}
 	defer func() { d.Jail(err == nil) }()
 
-	return d.Load(item, query, args...)
+	return d.FetchContext(ctx, item, query, args...)
 }
 
-func (c *conn) Save(item Shaper, query string, result Collector) (err error) {
+func (c *conn) SaveContext(ctx context.Context, item Shaper, query string, result Collector) (err error) {
 	var d Dealer
 	const emsg = "saving item"
 
-	if d, err = c.NewDealer(); err != nil {
+	// Writes go to the primary even with the read only context
+	if d, err = c.NewDealerContext(ctx, ReadOnly(false)); err != nil {
 		return errors.Wrap(err, emsg)
 	}
 	defer func() { d.Jail(err == nil) }()
 
-	return d.Save(item, query, result)
+	return d.SaveContext(ctx, item, query, result)
 }
 
-func (c *conn) Jail(commit bool) error { return nil }
+func (c *conn) NestContext(ctx context.Context) (Dealer, error) {
+	return c.NewDealerContext(ctx)
+}
+
+func (c *conn) JailContext(ctx context.Context, commit bool) error { return nil }
 
 func (c *conn) Close() {
 
@@ -151,11 +413,19 @@ func (c *conn) Close() {
 	}
 
 	c.Lock()
-	for name := range c.statements {
-		c.pool.Deallocate(name)
+	subs := make([]*subscription, 0, len(c.subscriptions))
+	for s := range c.subscriptions {
+		subs = append(subs, s)
 	}
 	c.Unlock()
 
+	for i := range subs {
+		subs[i].Close()
+	}
+
+	c.replicas.close()
+	c.replicas = nil
 	c.pool.Close()
 	c.pool = nil
+	c.stmts.reset()
 }
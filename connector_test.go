@@ -99,6 +99,16 @@ func TestConnector(t *testing.T) {
 	assert.Equal(t, 1, nu.ID)
 	assert.Equal(t, "test", nu.Name)
 
+	err = db.Fetch(nu, sqlSelect, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", nu.Name)
+
+	err = db.Fetch(nu, sqlSelect, 2)
+	assert.Equal(t, wpgx.ErrNotFound, errors.Cause(err))
+
+	err = db.Fetch(nu, `SELECT * FROM users, generate_series(1, 2);`)
+	assert.Equal(t, wpgx.ErrTooManyRows, errors.Cause(err))
+
 	err = db.Save(new(user), sqlInsert, nil)
 	assert.EqualError(t, err, "executing query: ERROR: null value in column \"name\" violates not-null constraint (SQLSTATE 23502)")
 
@@ -106,8 +116,8 @@ func TestConnector(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "INSERT INTO users (name) VALUES ($1) RETURNING id;", string(dump))
 
-	dump, err = ioutil.ReadFile(filepath.Join(reserve, sqlInsert+"_c3b374cf5a76aa557c625f9eaa6e91f462b67fe2.json"))
+	dump, err = ioutil.ReadFile(filepath.Join(reserve, sqlInsert+"_61cc79eb78af932baf155ee64bb705507e14f6c5.json"))
 	assert.NoError(t, err)
-	assert.Equal(t, "{\n  \"name\": null\n}", string(dump))
+	assert.Equal(t, "[\n  {\n    \"name\": \"name\",\n    \"type\": \"null\",\n    \"value\": null\n  }\n]", string(dump))
 
 }
@@ -0,0 +1,146 @@
+package wpgx
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// copyBatchSize is a number of rows, sent by one COPY command
+// Failed batch is dumped into reserve path as a whole
+const copyBatchSize = 10000
+
+func (t *tx) Copy(source Provider, table, key string, cols ...string) (int, error) {
+	return t.CopyContext(t.ctx, source, table, key, cols...)
+}
+
+func (t *tx) CopyContext(ctx context.Context, source Provider, table, key string, cols ...string) (total int, err error) {
+	const emsg = "copying items"
+
+	if err = t.ready(); err != nil {
+		return 0, errors.Wrap(err, emsg)
+	}
+
+	if len(cols) == 0 {
+		st, ok := t.c.stmts.get(key)
+		if !ok {
+			return 0, errors.New("unknown prepared query key: " + key)
+		}
+		cols = st.cols
+	}
+
+	if len(cols) == 0 {
+		return 0, errors.New("no columns to copy")
+	}
+
+	c := t.c
+	name := pgx.Identifier(strings.Split(table, "."))
+
+	// Dump without a cooked query is replayed with the insert of the same columns
+	var text string
+
+	if key == "" {
+		key, text = table, copyInsert(name, cols)
+	}
+	rows := make([][]interface{}, 0, copyBatchSize)
+
+	for more := true; more; {
+		rows = rows[:0]
+
+		for len(rows) < copyBatchSize {
+			var item Shaper
+
+			if item, err = source.Next(); err != nil {
+				return total, errors.Wrap(err, "reading source")
+			}
+
+			if item == nil {
+				more = false
+				break
+			}
+
+			row := make([]interface{}, len(cols))
+			model := item.Extrude()
+
+			for i := range cols {
+				row[i] = model.Translate(cols[i])
+			}
+
+			rows = append(rows, row)
+		}
+
+		if len(rows) == 0 {
+			break
+		}
+
+		var count int
+
+		finish := t.watch("COPY " + table)
+		count, err = t.CopyFrom(name, cols, &copySource{ctx: ctx, rows: rows, next: -1})
+		finish(count, err)
+		total += count
+
+		if err != nil {
+			if text != "" {
+				if rerr := c.reserveQuery(key, text); rerr != nil {
+					c.log(pgx.LogLevelError, "reserving data", Field{"error", rerr}, Field{"key", key})
+				}
+			}
+			c.reserve(key, cols, rows...)
+			return total, errors.Wrap(t.fail(ctx, t.query(err, "COPY "+table, nil)), emsg)
+		}
+	}
+
+	return total, nil
+}
+
+// copyInsert makes an insert of one row into the table columns
+func copyInsert(table pgx.Identifier, cols []string) string {
+	names := make([]string, len(cols))
+	places := make([]string, len(cols))
+
+	for i := range cols {
+		names[i] = pgx.Identifier{cols[i]}.Sanitize()
+		places[i] = "$" + strconv.Itoa(i+1)
+	}
+
+	return "INSERT INTO " + table.Sanitize() + " (" + strings.Join(names, ", ") +
+		") VALUES (" + strings.Join(places, ", ") + ");"
+}
+
+// copySource aborts the copy when the context is done
+type copySource struct {
+	ctx  context.Context
+	rows [][]interface{}
+	next int
+}
+
+func (s *copySource) Next() bool {
+	if s.ctx.Err() != nil {
+		return false
+	}
+	s.next++
+	return s.next < len(s.rows)
+}
+
+func (s *copySource) Values() ([]interface{}, error) { return s.rows[s.next], nil }
+func (s *copySource) Err() error                     { return s.ctx.Err() }
+
+func (c *conn) Copy(source Provider, table, key string, cols ...string) (int, error) {
+	return c.CopyContext(context.Background(), source, table, key, cols...)
+}
+
+func (c *conn) CopyContext(ctx context.Context, source Provider, table, key string, cols ...string) (total int, err error) {
+	var d Dealer
+	const emsg = "copying items"
+
+	if d, err = c.NewDealerContext(ctx); err != nil {
+		return 0, errors.Wrap(err, emsg)
+	}
+	defer func() { d.Jail(err == nil) }()
+
+	return d.CopyContext(ctx, source, table, key, cols...)
+}
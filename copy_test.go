@@ -0,0 +1,94 @@
+package wpgx_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+type userSource struct {
+	users []*user
+}
+
+func (s *userSource) Next() (wpgx.Shaper, error) {
+	if len(s.users) == 0 {
+		return nil, nil
+	}
+	item := s.users[0]
+	s.users = s.users[1:]
+	return item, nil
+}
+
+func TestCopy(t *testing.T) {
+	db, err := wpgx.Connect(connStr, wpgx.ReservePath(reserve))
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE copy_users (id int PRIMARY KEY, name text not null);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE copy_users;`)
+		assert.NoError(t, err)
+	}()
+
+	users := make([]*user, 0, 25000)
+	for i := 1; i <= 25000; i++ {
+		users = append(users, &user{ID: i, Name: "test"})
+	}
+
+	count, err := db.Copy(&userSource{users: users}, "copy_users", "", "id", "name")
+	assert.NoError(t, err)
+	assert.Equal(t, 25000, count)
+
+	sqlInsert, err := db.Cook(`INSERT INTO copy_users (id, name) VALUES ($1, $2);`, "id", "name")
+	assert.NoError(t, err)
+
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+
+	count, err = d.Copy(&userSource{users: []*user{{ID: 25001, Name: "key"}}}, "public.copy_users", sqlInsert)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = d.Copy(&userSource{}, "copy_users", "unknown")
+	assert.EqualError(t, err, "unknown prepared query key: unknown")
+	assert.NoError(t, d.Jail(true))
+
+	ints := make(wpgx.Ints, 0, 1)
+	err = db.Deal(&ints, `SELECT count(*) FROM copy_users;`)
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Ints{25001}, ints)
+
+	_, err = db.Copy(&userSource{users: []*user{{ID: 1}}}, "copy_users", sqlInsert)
+	assert.Error(t, err)
+
+	dumps, err := filepath.Glob(filepath.Join(reserve, sqlInsert+"_*.json"))
+	assert.NoError(t, err)
+	if assert.Len(t, dumps, 1) {
+		dump, err := ioutil.ReadFile(dumps[0])
+		assert.NoError(t, err)
+		assert.Contains(t, string(dump), `"value": 1`)
+		assert.NoError(t, os.Remove(dumps[0]))
+	}
+
+	// Copy without a key is dumped with the insert of its columns
+	_, err = db.Copy(&userSource{users: []*user{{ID: 1, Name: "dup"}}}, "public.copy_users", "", "id", "name")
+	assert.Error(t, err)
+
+	text, err := ioutil.ReadFile(filepath.Join(reserve, "public.copy_users.pgsql"))
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT INTO "public"."copy_users" ("id", "name") VALUES ($1, $2);`, string(text))
+
+	dumps, err = filepath.Glob(filepath.Join(reserve, "public.copy_users_*.json"))
+	assert.NoError(t, err)
+	assert.Len(t, dumps, 1)
+
+	for _, path := range append(dumps, filepath.Join(reserve, "public.copy_users.pgsql")) {
+		assert.NoError(t, os.Remove(path))
+	}
+}
@@ -0,0 +1,215 @@
+package wpgx
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// Table describes a table for generated statements
+//
+// Name may be qualified with a schema, like "public.users"
+//
+// Columns are saved by insert, update and upsert. Key columns are for update and delete
+// Conflict columns are the upsert target, Key is used when empty. Returning columns,
+// like generated ids and defaults, are returned into the Save result collector
+type Table struct {
+	Name      string
+	Columns   []string
+	Key       []string
+	Conflict  []string
+	Returning []string
+}
+
+// Crud has keys of the cooked statements, ready for Dealer.Save
+// Update and Delete are empty without Key, Upsert is empty without conflict target
+// or when the target columns are not saved. Update is also empty, when there are only key columns
+type Crud struct {
+	Insert string
+	Update string
+	Delete string
+	Upsert string
+}
+
+// Columns returns column names of the tagged struct in the order of fields
+func Columns(ptr interface{}) []string {
+	typ := reflect.TypeOf(ptr)
+
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := fieldsOf(typ.Elem())
+	cols := make([]string, 0, len(fields))
+
+	for name := range fields {
+		cols = append(cols, name)
+	}
+
+	sort.Slice(cols, func(i, j int) bool {
+		a, b := fields[cols[i]], fields[cols[j]]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	return cols
+}
+
+func (c *conn) Build(table Table) (Crud, error) {
+	return c.BuildContext(context.Background(), table)
+}
+
+func (c *conn) BuildContext(ctx context.Context, table Table) (crud Crud, err error) {
+	var d Dealer
+	const emsg = "building statements"
+
+	if table.Name == "" {
+		return crud, errors.New("no table name")
+	}
+
+	if len(table.Columns) == 0 {
+		return crud, errors.New("no columns")
+	}
+
+	if d, err = c.NewDealerContext(ctx); err != nil {
+		return crud, errors.Wrap(err, emsg)
+	}
+	defer func() { d.Jail(err == nil) }()
+
+	b := &builder{Table: table, name: pgx.Identifier(strings.Split(table.Name, ".")).Sanitize()}
+
+	if crud.Insert, err = d.CookContext(ctx, b.insert(), table.Columns...); err != nil {
+		return crud, errors.Wrap(err, "building insert")
+	}
+
+	if len(table.Key) > 0 {
+		// Nothing to set, when all the columns are keys
+		if text, cols := b.update(); len(cols) > len(table.Key) {
+			if crud.Update, err = d.CookContext(ctx, text, cols...); err != nil {
+				return crud, errors.Wrap(err, "building update")
+			}
+		}
+
+		if crud.Delete, err = d.CookContext(ctx, b.delete(), table.Key...); err != nil {
+			return crud, errors.Wrap(err, "building delete")
+		}
+	}
+
+	// Upsert can't find the conflict without the target columns, e.g. serial keys
+	if target := b.target(); len(target) > 0 && b.saved(target) {
+		if crud.Upsert, err = d.CookContext(ctx, b.upsert(target), table.Columns...); err != nil {
+			return crud, errors.Wrap(err, "building upsert")
+		}
+	}
+
+	return crud, nil
+}
+
+type builder struct {
+	Table
+	name string
+}
+
+func (b *builder) insert() string {
+	return "INSERT INTO " + b.name + " (" + quote(b.Columns) + ") VALUES (" + places(1, len(b.Columns)) + ")" + b.returning()
+}
+
+func (b *builder) update() (string, []string) {
+	data := except(b.Columns, b.Key)
+	cols := append(data[:len(data):len(data)], b.Key...)
+	text := "UPDATE " + b.name + " SET " + assign(data, 1, ", ") + " WHERE " + assign(b.Key, len(data)+1, " AND ")
+	return text + b.returning(), cols
+}
+
+func (b *builder) delete() string {
+	return "DELETE FROM " + b.name + " WHERE " + assign(b.Key, 1, " AND ") + b.returning()
+}
+
+func (b *builder) upsert(target []string) string {
+	text := "INSERT INTO " + b.name + " (" + quote(b.Columns) + ") VALUES (" + places(1, len(b.Columns)) + ")"
+	text += " ON CONFLICT (" + quote(target) + ")"
+
+	data := except(b.Columns, target)
+
+	if len(data) == 0 {
+		return text + " DO NOTHING" + b.returning()
+	}
+
+	sets := make([]string, len(data))
+	for i := range data {
+		name := pgx.Identifier{data[i]}.Sanitize()
+		sets[i] = name + " = EXCLUDED." + name
+	}
+
+	return text + " DO UPDATE SET " + strings.Join(sets, ", ") + b.returning()
+}
+
+func (b *builder) returning() string {
+	if len(b.Returning) == 0 {
+		return ";"
+	}
+	return " RETURNING " + quote(b.Returning) + ";"
+}
+
+func (b *builder) target() []string {
+	if len(b.Conflict) > 0 {
+		return b.Conflict
+	}
+	return b.Key
+}
+
+func (b *builder) saved(target []string) bool {
+	return len(except(target, b.Columns)) == 0
+}
+
+// quote makes a list of sanitized column names
+func quote(cols []string) string {
+	names := make([]string, len(cols))
+	for i := range cols {
+		names[i] = pgx.Identifier{cols[i]}.Sanitize()
+	}
+	return strings.Join(names, ", ")
+}
+
+// places makes a list of positional parameters, starting from the first one
+func places(first, count int) string {
+	list := make([]string, count)
+	for i := range list {
+		list[i] = "$" + strconv.Itoa(first+i)
+	}
+	return strings.Join(list, ", ")
+}
+
+// assign makes a list of column and parameter pairs, like "name" = $1
+func assign(cols []string, first int, sep string) string {
+	pairs := make([]string, len(cols))
+	for i := range cols {
+		pairs[i] = pgx.Identifier{cols[i]}.Sanitize() + " = $" + strconv.Itoa(first+i)
+	}
+	return strings.Join(pairs, sep)
+}
+
+// except returns columns, that are not in the skip list
+func except(cols, skip []string) []string {
+	rest := make([]string, 0, len(cols))
+
+next:
+	for i := range cols {
+		for j := range skip {
+			if cols[i] == skip[j] {
+				continue next
+			}
+		}
+		rest = append(rest, cols[i])
+	}
+	return rest
+}
@@ -0,0 +1,103 @@
+package wpgx_test
+
+import (
+	"testing"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+type crudUser struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+	Age  int    `db:"age"`
+}
+
+func TestCrud(t *testing.T) {
+	assert.Equal(t, []string{"id", "name", "age"}, wpgx.Columns(&crudUser{}))
+	assert.Equal(t, []string{"id", "name", "age"}, wpgx.Columns(&tagUser{}))
+	assert.Nil(t, wpgx.Columns(crudUser{}))
+
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE crud_users (id serial PRIMARY KEY, name text UNIQUE, age int DEFAULT 18);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE crud_users;`)
+		assert.NoError(t, err)
+	}()
+
+	_, err = db.Build(wpgx.Table{Columns: []string{"name"}})
+	assert.EqualError(t, err, "no table name")
+
+	_, err = db.Build(wpgx.Table{Name: "crud_users"})
+	assert.EqualError(t, err, "no columns")
+
+	// Serial key is not saved, so there is no upsert
+	crud, err := db.Build(wpgx.Table{Name: "crud_users", Columns: []string{"name"}, Key: []string{"id"}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, crud.Update)
+	assert.Empty(t, crud.Upsert)
+
+	_, err = db.Build(wpgx.Table{Name: "crud_users", Columns: []string{"nothing"}})
+	assert.EqualError(t, err, "building insert: preparing statement: ERROR: column \"nothing\" of relation \"crud_users\" does not exist (SQLSTATE 42703)")
+
+	crud, err = db.Build(wpgx.Table{
+		Name:      "public.crud_users",
+		Columns:   []string{"name"},
+		Key:       []string{"id"},
+		Conflict:  []string{"name"},
+		Returning: []string{"id", "age"},
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, crud.Insert)
+	assert.NotEmpty(t, crud.Delete)
+	assert.NotEmpty(t, crud.Upsert)
+	assert.NotEmpty(t, crud.Update)
+
+	// Only key columns are left, so there is nothing to update
+	keys, err := db.Build(wpgx.Table{Name: "crud_users", Columns: []string{"id"}, Key: []string{"id"}})
+	assert.NoError(t, err)
+	assert.Empty(t, keys.Update)
+	assert.NotEmpty(t, keys.Upsert)
+
+	var list []crudUser
+
+	err = db.Save(wpgx.Shape(&crudUser{Name: "first"}), crud.Insert, wpgx.Slice(&list))
+	assert.NoError(t, err)
+	assert.Equal(t, []crudUser{{ID: 1, Age: 18}}, list)
+
+	err = db.Save(wpgx.Shape(&crudUser{Name: "first"}), crud.Upsert, wpgx.Slice(&list))
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	crud, err = db.Build(wpgx.Table{
+		Name:      "crud_users",
+		Columns:   wpgx.Columns(&crudUser{}),
+		Key:       []string{"id"},
+		Returning: []string{"age"},
+	})
+	assert.NoError(t, err)
+
+	list = list[:0]
+	err = db.Save(wpgx.Shape(&crudUser{ID: 1, Name: "second", Age: 21}), crud.Update, wpgx.Slice(&list))
+	assert.NoError(t, err)
+	assert.Equal(t, []crudUser{{Age: 21}}, list)
+
+	err = db.Save(wpgx.Shape(&crudUser{ID: 1, Name: "third", Age: 30}), crud.Upsert, nil)
+	assert.NoError(t, err)
+
+	err = db.Load(wpgx.Shape(&list[0]), `SELECT * FROM crud_users WHERE id = 1;`)
+	assert.NoError(t, err)
+	assert.Equal(t, crudUser{ID: 1, Name: "third", Age: 30}, list[0])
+
+	err = db.Save(wpgx.Shape(&crudUser{ID: 1}), crud.Delete, nil)
+	assert.NoError(t, err)
+
+	ints := make(wpgx.Ints, 0, 1)
+	err = db.Deal(&ints, `SELECT count(*) FROM crud_users;`)
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Ints{0}, ints)
+}
@@ -0,0 +1,220 @@
+package wpgx
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoScroll occurs when a cursor, that is not scrollable, is moved backward
+var ErrNoScroll = errors.New("cursor is not scrollable")
+
+// Cursor reads a huge result chunk by chunk with a server side cursor
+// Consumer may pause between chunks, but the dealer is busy until the cursor is closed
+//
+// Next fetches up to count next rows into the collector. It returns number of fetched rows,
+// less than count means the end of the result
+//
+// Prior fetches up to count previous rows. Only scrollable cursor can do it
+//
+// Seek moves the cursor to the absolute row position. Zero is before the first row,
+// negative ones are counted from the end. Only scrollable cursor can do it, because
+// the server may rewind the cursor to find the position
+//
+// Close closes the cursor. It is closed by Jail of the dealer as well
+type Cursor interface {
+	Next(result Collector, count int) (int, error)
+	Prior(result Collector, count int) (int, error)
+	Seek(pos int) error
+	Close() error
+
+	NextContext(ctx context.Context, result Collector, count int) (int, error)
+	PriorContext(ctx context.Context, result Collector, count int) (int, error)
+	SeekContext(ctx context.Context, pos int) error
+	CloseContext(ctx context.Context) error
+}
+
+// cursorSeq makes cursor names unique for all the dealers
+var cursorSeq uint64
+
+type cursor struct {
+	t      *tx
+	name   string
+	scroll bool
+	closed bool
+
+	// Dealer is opened just for the cursor, so it is jailed by Close
+	own bool
+}
+
+func (t *tx) Declare(scroll bool, query string, args ...interface{}) (Cursor, error) {
+	return t.DeclareContext(t.ctx, scroll, query, args...)
+}
+
+func (t *tx) DeclareContext(ctx context.Context, scroll bool, query string, args ...interface{}) (Cursor, error) {
+	const emsg = "declaring cursor"
+
+	if err := t.ready(); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	// Cursor can't be declared for a prepared statement, so the text is used
+	if st, ok := t.c.stmts.get(query); ok {
+		query = st.text
+	} else if err := unknown(query); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	c := &cursor{
+		t:      t,
+		name:   "wpgx_cursor_" + strconv.FormatUint(atomic.AddUint64(&cursorSeq, 1), 10),
+		scroll: scroll,
+	}
+
+	text := "DECLARE " + c.name + " NO SCROLL CURSOR FOR " + query
+	if scroll {
+		text = "DECLARE " + c.name + " SCROLL CURSOR FOR " + query
+	}
+
+	if _, err := t.ExecEx(ctx, text, nil, args...); err != nil {
+		return nil, errors.Wrap(t.fail(ctx, t.query(err, query, args)), emsg)
+	}
+
+	t.cursors = append(t.cursors, c)
+	return c, nil
+}
+
+func (c *conn) Declare(scroll bool, query string, args ...interface{}) (Cursor, error) {
+	return c.DeclareContext(context.Background(), scroll, query, args...)
+}
+
+func (c *conn) DeclareContext(ctx context.Context, scroll bool, query string, args ...interface{}) (Cursor, error) {
+	const emsg = "declaring cursor"
+
+	d, err := c.NewDealerContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	cur, err := d.DeclareContext(ctx, scroll, query, args...)
+	if err != nil {
+		d.Jail(false)
+		return nil, err
+	}
+
+	cur.(*cursor).own = true
+	return cur, nil
+}
+
+// closeCursors closes cursors, that are still open
+// They are closed by the server at the end of the transaction, but not the savepoint
+func (t *tx) closeCursors(ctx context.Context) (err error) {
+	for _, c := range t.cursors {
+		if !c.closed {
+			c.closed = true
+			if _, err = t.ExecEx(ctx, "CLOSE "+c.name, nil); err != nil {
+				return err
+			}
+		}
+	}
+	t.cursors = nil
+	return nil
+}
+
+func (c *cursor) ready() error {
+	if c.closed {
+		return ErrConnClosed
+	}
+	return c.t.ready()
+}
+
+func (c *cursor) Next(result Collector, count int) (int, error) {
+	return c.NextContext(c.t.ctx, result, count)
+}
+
+func (c *cursor) Prior(result Collector, count int) (int, error) {
+	return c.PriorContext(c.t.ctx, result, count)
+}
+
+func (c *cursor) Seek(pos int) error {
+	return c.SeekContext(c.t.ctx, pos)
+}
+
+func (c *cursor) Close() error {
+	return c.CloseContext(c.t.ctx)
+}
+
+func (c *cursor) NextContext(ctx context.Context, result Collector, count int) (int, error) {
+	return c.fetch(ctx, "FORWARD", result, count)
+}
+
+func (c *cursor) PriorContext(ctx context.Context, result Collector, count int) (int, error) {
+	if !c.scroll {
+		return 0, errors.Wrap(ErrNoScroll, "fetching cursor")
+	}
+	return c.fetch(ctx, "BACKWARD", result, count)
+}
+
+func (c *cursor) SeekContext(ctx context.Context, pos int) (err error) {
+	const emsg = "moving cursor"
+
+	if err = c.ready(); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+
+	if !c.scroll {
+		return errors.Wrap(ErrNoScroll, emsg)
+	}
+
+	_, err = c.t.ExecEx(ctx, "MOVE ABSOLUTE "+strconv.Itoa(pos)+" FROM "+c.name, nil)
+	return errors.Wrap(c.t.fail(ctx, err), emsg)
+}
+
+func (c *cursor) CloseContext(ctx context.Context) (err error) {
+	const emsg = "closing cursor"
+
+	if err = c.ready(); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+
+	c.closed = true
+
+	if _, err = c.t.ExecEx(ctx, "CLOSE "+c.name, nil); err != nil {
+		err = c.t.fail(ctx, err)
+	}
+
+	if c.own {
+		if jerr := c.t.JailContext(ctx, err == nil); err == nil {
+			err = jerr
+		}
+	}
+
+	return errors.Wrap(err, emsg)
+}
+
+func (c *cursor) fetch(ctx context.Context, dir string, result Collector, count int) (int, error) {
+	const emsg = "fetching cursor"
+
+	if err := c.ready(); err != nil {
+		return 0, errors.Wrap(err, emsg)
+	}
+
+	if count <= 0 {
+		return 0, nil
+	}
+
+	rows, err := c.t.QueryEx(ctx, "FETCH "+dir+" "+strconv.Itoa(count)+" FROM "+c.name, nil)
+	if err != nil {
+		return 0, errors.Wrap(c.t.fail(ctx, err), emsg)
+	}
+	defer rows.Close()
+
+	n, err := fetch(rows, result)
+	if err != nil {
+		return n, err
+	}
+
+	return n, errors.Wrap(c.t.fail(ctx, rows.Err()), "checking result")
+}
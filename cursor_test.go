@@ -0,0 +1,96 @@
+package wpgx_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlSeries, err := db.Cook(`SELECT generate_series(1, $1::int) AS id;`)
+	assert.NoError(t, err)
+
+	// Connector opens the dealer just for the cursor
+	cur, err := db.Declare(false, sqlSeries, 5)
+	assert.NoError(t, err)
+
+	ints := make(wpgx.Ints, 0, 5)
+
+	count, err := cur.Next(&ints, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, wpgx.Ints{1, 2}, ints)
+
+	count, err = cur.Next(&ints, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, wpgx.Ints{1, 2, 3, 4, 5}, ints)
+
+	count, err = cur.Next(&ints, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	_, err = cur.Prior(&ints, 1)
+	assert.Equal(t, wpgx.ErrNoScroll, errors.Cause(err))
+
+	// Dealer is still alive after that
+	err = cur.Seek(1)
+	assert.Equal(t, wpgx.ErrNoScroll, errors.Cause(err))
+
+	assert.NoError(t, cur.Close())
+
+	_, err = cur.Next(&ints, 1)
+	assert.Equal(t, wpgx.ErrConnClosed, errors.Cause(err))
+
+	// Scrollable one is moved back and forth
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+
+	cur, err = d.Declare(true, `SELECT generate_series(1, 5) AS id;`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cur.Seek(-1))
+
+	ints = ints[:0]
+	count, err = cur.Prior(&ints, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, wpgx.Ints{4, 3}, ints)
+
+	assert.NoError(t, cur.Seek(0))
+
+	ints = ints[:0]
+	count, err = cur.Next(&ints, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Ints{1}, ints)
+
+	_, err = d.Declare(false, `SELECT FROM WHERE;`)
+	assert.EqualError(t, err, "declaring cursor: ERROR: syntax error at or near \"WHERE\" (SQLSTATE 42601)")
+	assert.NoError(t, d.Jail(false))
+
+	// Cursor of the nested dealer is closed on Jail
+	d, err = db.NewDealer()
+	assert.NoError(t, err)
+
+	n, err := d.Nest()
+	assert.NoError(t, err)
+
+	cur, err = n.Declare(false, `SELECT 1 AS id;`)
+	assert.NoError(t, err)
+	assert.NoError(t, n.Jail(true))
+
+	_, err = cur.Next(&ints, 1)
+	assert.Equal(t, wpgx.ErrConnClosed, errors.Cause(err))
+
+	ints = ints[:0]
+	err = d.Deal(&ints, `SELECT count(*) FROM pg_cursors;`)
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Ints{0}, ints)
+	assert.NoError(t, d.Jail(true))
+}
@@ -1,12 +1,11 @@
 package wpgx
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/pkg/errors"
@@ -14,158 +13,402 @@ import (
 
 // Dealer is an active subject, like an opened transaction, for query performing
 //
+// Each method has a Context version. When the context is done, running statement
+// is canceled on the server and the whole transaction is rolled back
+//
+// Cook prepares query and saves it for further execution. Columns are for Save and Copy
+// Query may have :name or @name placeholders instead of $1, then columns are taken from them
+// Uncook forgets the cooked query and deallocates it
+//
 // Deal! It executes query and loads result into a data collector. Pass nil when no result needed
 //
 // Load gets just one item from the database. When no collection needed
 //
+// Fetch is a strict Load. It fails with ErrNotFound without rows and ErrTooManyRows
+// when there are more than one
+//
 // Save inserts item into database. Result may need for getting new ID or properties
 //
+// Copy inserts items from the source into the table with COPY command. Columns are taken
+// from the prepared query key, when no explicit ones. It returns number of copied rows
+//
+// Notify sends a notification to the channel. It is delivered only when transaction commits
+//
+// Batch makes a queue of operations. They are sent in one round trip
+//
+// Declare opens a server side cursor for the query. Scrollable one can move backward
+//
+// Nest opens a nested dealer, backed by a savepoint. Jail of the nested dealer
+// releases the savepoint or rolls back to it. Parent can't be used or committed while it is open,
+// but its rollback closes the nested dealer as well
+//
+// Mode returns transaction options, which dealer was started with. Empty means server default
+//
 // Jail (aka Close) ends a transaction with commit or rollback respective to the flag
 type Dealer interface {
 	Cook(text string, cols ...string) (string, error)
+	Uncook(key string) error
 	Deal(result Collector, query string, args ...interface{}) error
 	Load(item Shaper, query string, args ...interface{}) error
+	Fetch(item Shaper, query string, args ...interface{}) error
 	Save(item Shaper, key string, result Collector) error
+	Copy(source Provider, table, key string, cols ...string) (int, error)
+	Notify(channel, payload string) error
+	Batch() Batch
+	Declare(scroll bool, query string, args ...interface{}) (Cursor, error)
+	Nest() (Dealer, error)
+	Mode() pgx.TxOptions
 	Jail(commit bool) error
+
+	CookContext(ctx context.Context, text string, cols ...string) (string, error)
+	UncookContext(ctx context.Context, key string) error
+	DealContext(ctx context.Context, result Collector, query string, args ...interface{}) error
+	LoadContext(ctx context.Context, item Shaper, query string, args ...interface{}) error
+	FetchContext(ctx context.Context, item Shaper, query string, args ...interface{}) error
+	SaveContext(ctx context.Context, item Shaper, key string, result Collector) error
+	CopyContext(ctx context.Context, source Provider, table, key string, cols ...string) (int, error)
+	NotifyContext(ctx context.Context, channel, payload string) error
+	DeclareContext(ctx context.Context, scroll bool, query string, args ...interface{}) (Cursor, error)
+	NestContext(ctx context.Context) (Dealer, error)
+	JailContext(ctx context.Context, commit bool) error
 }
 
 type tx struct {
 	*pgx.Tx
-	c *conn
+	c      *conn
+	cn     *pgx.Conn
+	pool   *pgx.ConnPool
+	start  time.Time
+	span   Span
+	tctx   context.Context
+	ctx    context.Context
+	mode   pgx.TxOptions
+	parent *tx
+	child  *tx
+	depth  int
+
+	cursors  []*cursor
+	watching func(rows int, err error)
+
+	// Result cache is used by one call of the connector or a read only dealer
+	// Generation of the cache is taken before the transaction begins
+	single bool
+	gen    uint64
 }
 
+// ready checks, that the dealer can run queries. It can't, while the nested one is open
 func (t *tx) ready() error {
+	if err := t.alive(); err != nil {
+		return err
+	}
+	if t.child != nil {
+		return ErrNestedDealer
+	}
+	return nil
+}
+
+// alive checks, that the dealer and all its parents are not closed
+func (t *tx) alive() error {
 	if t == nil || t.Tx == nil || t.c == nil {
 		return ErrConnClosed
 	}
+	if t.parent != nil {
+		return t.parent.alive()
+	}
 	return t.c.ready()
 }
 
-func (t *tx) Cook(text string, cols ...string) (key string, err error) {
+// savepoint is a name of the savepoint, that backs nested dealer
+func (t *tx) savepoint() string { return "wpgx_" + strconv.Itoa(t.depth) }
+
+// rollback discards all changes of the dealer without a context
+func (t *tx) rollback() (err error) {
+	if t.parent == nil {
+		return t.Rollback()
+	}
+	if _, err = t.Exec("ROLLBACK TO SAVEPOINT " + t.savepoint()); err != nil {
+		return
+	}
+	_, err = t.Exec("RELEASE SAVEPOINT " + t.savepoint())
+	return
+}
+
+// release ends the nested dealer, keeping or discarding its changes
+func (t *tx) release(ctx context.Context, commit bool) (err error) {
+	if !commit {
+		if _, err = t.ExecEx(ctx, "ROLLBACK TO SAVEPOINT "+t.savepoint(), nil); err != nil {
+			return
+		}
+	}
+	_, err = t.ExecEx(ctx, "RELEASE SAVEPOINT "+t.savepoint(), nil)
+	return
+}
+
+// close detaches the dealer with all the nested ones
+// Connection of the root dealer goes back to the pool
+func (t *tx) close() {
+	if t.child != nil {
+		t.child.close()
+	}
+	if t.parent != nil {
+		t.parent.child = nil
+	}
+	if t.parent == nil && t.cn != nil && t.pool != nil {
+		t.pool.Release(t.cn)
+	}
+	t.Tx = nil
+	t.c = nil
+	t.cn = nil
+}
+
+// fail rolls back the transaction when the context is done,
+// so the cause of the error is a context error
+func (t *tx) fail(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	// The query is finished before the rollback, so the events come in order
+	if t.watching != nil {
+		t.watching(0, ctx.Err())
+	}
+
+	if t.Tx != nil {
+		t.rollback()
+		t.done(false, ctx.Err())
+	}
+
+	t.close()
+	return ctx.Err()
+}
+
+func (t *tx) Cook(text string, cols ...string) (string, error) {
+	return t.CookContext(t.ctx, text, cols...)
+}
+
+func (t *tx) Uncook(key string) error {
+	return t.UncookContext(t.ctx, key)
+}
+
+func (t *tx) Deal(result Collector, query string, args ...interface{}) error {
+	return t.DealContext(t.ctx, result, query, args...)
+}
+
+func (t *tx) Load(item Shaper, query string, args ...interface{}) error {
+	return t.LoadContext(t.ctx, item, query, args...)
+}
+
+func (t *tx) Fetch(item Shaper, query string, args ...interface{}) error {
+	return t.FetchContext(t.ctx, item, query, args...)
+}
+
+func (t *tx) Save(item Shaper, key string, result Collector) error {
+	return t.SaveContext(t.ctx, item, key, result)
+}
+
+func (t *tx) Nest() (Dealer, error) {
+	return t.NestContext(t.ctx)
+}
+
+func (t *tx) Mode() pgx.TxOptions { return t.mode }
+
+func (t *tx) Jail(commit bool) error {
+	return t.JailContext(t.ctx, commit)
+}
+
+func (t *tx) CookContext(ctx context.Context, text string, cols ...string) (key string, err error) {
 	const emsg = "preparing statement"
 
+	end := t.trace("wpgx.cook", text)
+	defer func() { end(-1, err) }()
+
 	if err = t.ready(); err != nil {
 		return "", errors.Wrap(err, emsg)
 	}
 
-	sum := sha1.Sum([]byte(text))
-	key = hex.EncodeToString(sum[:])
+	var query string
 
-	if _, err = t.Tx.Prepare(key, text); err != nil {
+	if query, cols, err = params(text, cols...); err != nil {
 		return "", errors.Wrap(err, emsg)
 	}
 
-	t.c.Lock()
-	t.c.statements[key] = cols
-	t.c.Unlock()
+	// Key is made of the source text, so the reserved one is cooked the same way
+	sum := sha1.Sum([]byte(text))
+	key = hex.EncodeToString(sum[:])
 
-	if t.c.reservePath == "" {
-		return
+	if _, err = t.Tx.PrepareEx(ctx, key, query, nil); err != nil {
+		return "", errors.Wrap(t.fail(ctx, t.query(err, query, nil)), emsg)
 	}
 
-	path := filepath.Join(t.c.reservePath, key+".pgsql")
-	err = ioutil.WriteFile(path, []byte(text), 0755)
-	return key, errors.Wrap(err, emsg)
+	t.c.stmts.add(key, query, cols)
+	t.c.stmts.mark(t.cn, key)
+
+	return key, errors.Wrap(t.c.reserveQuery(key, text), emsg)
 }
 
-func (t *tx) Deal(result Collector, query string, args ...interface{}) (err error) {
+func (t *tx) UncookContext(ctx context.Context, key string) (err error) {
+	const emsg = "deallocating statement"
 
 	if err = t.ready(); err != nil {
-		return errors.Wrap(err, "executing query")
+		return errors.Wrap(err, emsg)
 	}
 
-	if result == nil {
-		_, err = t.Exec(query, args...)
-		return errors.Wrap(err, "executing query")
+	if !t.c.stmts.remove(key) {
+		return errors.New("unknown prepared query key: " + key)
 	}
 
-	var rows *pgx.Rows
+	if err = ctx.Err(); err != nil {
+		return errors.Wrap(t.fail(ctx, err), emsg)
+	}
 
-	if rows, err = t.Query(query, args...); err != nil {
-		return errors.Wrap(err, "selecting data")
+	// Other connections deallocate it before next use
+	for _, name := range t.c.stmts.drain(t.cn) {
+		if err = t.cn.Deallocate(name); err != nil {
+			return errors.Wrap(err, emsg)
+		}
 	}
-	defer rows.Close()
 
-	names := rows.FieldDescriptions()
-	places := make([]interface{}, len(names))
+	return nil
+}
 
-	for rows.Next() {
-		item := result.NewItem()
+func (t *tx) DealContext(ctx context.Context, result Collector, query string, args ...interface{}) (err error) {
+	var n int
 
-		if item == nil {
-			break
-		}
+	end := t.trace("wpgx.deal", query)
+	defer func() { end(n, err) }()
+	defer t.clock(query, args)()
 
-		model := item.Extrude()
+	n, err = t.deal(ctx, result, query, args)
+	return err
+}
 
-		for i := range names {
-			places[i] = model.Translate(names[i].Name)
-		}
+// deal executes the query and returns a number of collected or affected rows
+func (t *tx) deal(ctx context.Context, result Collector, query string, args []interface{}) (n int, err error) {
 
-		if err = rows.Scan(places...); err != nil {
-			return errors.Wrap(err, "scanning data row")
-		}
+	if err = t.ready(); err != nil {
+		return 0, errors.Wrap(err, "executing query")
+	}
 
-		if err = item.Receive(model); err != nil {
-			return errors.Wrap(err, "receiving model")
+	// Cached rows are loaded without a round trip
+	var ck string
+
+	if result != nil && t.c.cache != nil && (t.single || t.mode.AccessMode == pgx.ReadOnly) {
+		if ck = t.c.cache.key(query, args); ck != "" {
+			if entry, ok := t.c.cache.get(ck); ok {
+				return entry.feed(result)
+			}
 		}
+	}
 
-		if err = result.Collect(item); err != nil {
-			return errors.Wrap(err, "collecting item")
+	if err = t.prepare(ctx, query); err != nil {
+		return 0, errors.Wrap(t.fail(ctx, t.query(err, query, nil)), "preparing statement")
+	}
+
+	finish := t.watch(query)
+	defer func() { finish(n, err) }()
+
+	if result == nil {
+		var tag pgx.CommandTag
+
+		tag, err = t.ExecEx(ctx, query, nil, args...)
+		n = int(tag.RowsAffected())
+		return n, errors.Wrap(t.fail(ctx, t.query(err, query, args)), "executing query")
+	}
+
+	var rows *pgx.Rows
+
+	if rows, err = t.QueryEx(ctx, query, nil, args...); err != nil {
+		return 0, errors.Wrap(t.fail(ctx, t.query(err, query, args)), "selecting data")
+	}
+	defer rows.Close()
+
+	if ck == "" {
+		if n, err = fetch(rows, result); err != nil {
+			return n, err
 		}
+
+		return n, errors.Wrap(t.fail(ctx, t.query(rows.Err(), query, args)), "checking result")
+	}
+
+	entry, err := capture(rows, t.cn.ConnInfo, ck, t.gen)
+	if err != nil {
+		return 0, err
 	}
 
-	return errors.Wrap(rows.Err(), "checking result")
+	if err = t.fail(ctx, t.query(rows.Err(), query, args)); err != nil {
+		return 0, errors.Wrap(err, "checking result")
+	}
+
+	t.c.cache.put(entry)
+	return entry.feed(result)
 }
 
-func (t *tx) Load(item Shaper, query string, args ...interface{}) (err error) {
+func (t *tx) LoadContext(ctx context.Context, item Shaper, query string, args ...interface{}) (err error) {
+	var n int
+
+	end := t.trace("wpgx.load", query)
+	defer func() { end(n, err) }()
+	defer t.clock(query, args)()
 
 	if err = t.ready(); err != nil {
 		return errors.Wrap(err, "loading item")
 	}
 
+	if err = t.prepare(ctx, query); err != nil {
+		return errors.Wrap(t.fail(ctx, t.query(err, query, nil)), "preparing statement")
+	}
+
+	finish := t.watch(query)
+	defer func() { finish(n, err) }()
+
 	var rows *pgx.Rows
 
-	if rows, err = t.Query(query, args...); err != nil {
-		return errors.Wrap(err, "selecting data")
+	if rows, err = t.QueryEx(ctx, query, nil, args...); err != nil {
+		return errors.Wrap(t.fail(ctx, t.query(err, query, args)), "selecting data")
 	}
 	defer rows.Close()
 
-	names := rows.FieldDescriptions()
-	places := make([]interface{}, len(names))
+	if n, err = fetch(rows, &oneItem{item: item}); err != nil {
+		return err
+	}
 
-	if rows.Next() {
-		model := item.Extrude()
+	return errors.Wrap(t.fail(ctx, t.query(rows.Err(), query, args)), "checking result")
+}
 
-		for i := range names {
-			places[i] = model.Translate(names[i].Name)
-		}
+func (t *tx) FetchContext(ctx context.Context, item Shaper, query string, args ...interface{}) (err error) {
+	one := &oneItem{item: item}
 
-		if err = rows.Scan(places...); err != nil {
-			return errors.Wrap(err, "scanning data row")
-		}
+	if err = t.DealContext(ctx, one, query, args...); err != nil {
+		return err
+	}
 
-		if err = item.Receive(model); err != nil {
-			return errors.Wrap(err, "receiving model")
-		}
+	if !one.done {
+		return errors.Wrap(ErrNotFound, "fetching item")
+	}
+
+	if one.more {
+		return errors.Wrap(ErrTooManyRows, "fetching item")
 	}
 
-	return errors.Wrap(rows.Err(), "checking result")
+	return nil
 }
 
-func (t *tx) Save(item Shaper, key string, result Collector) (err error) {
+func (t *tx) SaveContext(ctx context.Context, item Shaper, key string, result Collector) (err error) {
+	var n int
+
+	end := t.trace("wpgx.save", key)
+	defer func() { end(n, err) }()
 
 	if err = t.ready(); err != nil {
 		return errors.Wrap(err, "saving item")
 	}
 
-	t.c.RLock()
-	cols, ok := t.c.statements[key]
-	t.c.RUnlock()
+	st, ok := t.c.stmts.get(key)
 	if !ok {
 		return errors.New("unknown prepared query key: " + key)
 	}
 
+	cols := st.cols
 	args := make([]interface{}, len(cols))
 	model := item.Extrude()
 
@@ -173,45 +416,151 @@ func (t *tx) Save(item Shaper, key string, result Collector) (err error) {
 		args[i] = model.Translate(cols[i])
 	}
 
+	// Dealer may be closed by the context, so keep the connector for reserve
+	c := t.c
 	defer func() {
-		if err == nil || t.c.reservePath == "" {
-			return
+		if err != nil {
+			c.reserve(key, cols, args)
 		}
-		const emsg = "reserving data"
-		const etpl = "\n%+v\nreserve data: %+v"
+	}()
+	defer t.clock(key, args)()
 
-		dump := make(map[string]interface{})
-		for i := range cols {
-			dump[cols[i]] = args[i]
-		}
+	n, err = t.deal(ctx, result, key, args)
+	return err
+}
 
-		text, ex := json.MarshalIndent(dump, "", "  ")
-		if ex != nil {
-			fmt.Printf(etpl, errors.Wrap(ex, emsg), dump)
-		}
+func (t *tx) NestContext(ctx context.Context) (Dealer, error) {
+	const emsg = "nesting dealer"
 
-		sum := sha1.Sum([]byte(text))
-		hash := hex.EncodeToString(sum[:])
-		path := filepath.Join(t.c.reservePath, key+"_"+hash+".json")
+	if err := t.ready(); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
 
-		if ex = ioutil.WriteFile(path, text, 0755); ex != nil {
-			fmt.Printf(etpl, errors.Wrap(ex, emsg), dump)
-		}
-	}()
+	d := &tx{Tx: t.Tx, c: t.c, cn: t.cn, pool: t.pool, ctx: ctx, tctx: t.tctx, mode: t.mode, gen: t.gen, parent: t, depth: t.depth + 1}
 
-	return t.Deal(result, key, args...)
+	if _, err := t.ExecEx(ctx, "SAVEPOINT "+d.savepoint(), nil); err != nil {
+		return nil, errors.Wrap(t.fail(ctx, err), emsg)
+	}
+
+	t.child = d
+	return d, nil
 }
 
-func (t *tx) Jail(commit bool) (err error) {
+func (t *tx) JailContext(ctx context.Context, commit bool) (err error) {
 	const emsg = "closing transaction"
+
+	if err = t.alive(); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+
+	// Nested changes can't be saved without the nested dealer itself,
+	// but when nothing is saved, all of them can be jailed at once
+	nested := t.child != nil
+	if nested && commit {
+		return errors.Wrap(ErrNestedDealer, emsg)
+	}
+
 	defer func() {
-		t.Rollback()
-		t.Tx = nil
-		t.c = nil
-		t = nil
+		if t.parent == nil && t.Tx != nil {
+			t.Rollback()
+		}
+		t.close()
+		if nested && err == nil {
+			err = errors.Wrap(ErrNestedDealer, emsg)
+		}
 	}()
-	if !commit {
-		return errors.Wrap(t.Rollback(), emsg)
+
+	if t.parent != nil {
+		// Cursors of the rolled back savepoint are closed by the server
+		if commit {
+			if err = t.closeCursors(ctx); err != nil {
+				return errors.Wrap(t.fail(ctx, err), emsg)
+			}
+		}
+		return errors.Wrap(t.fail(ctx, t.release(ctx, commit)), emsg)
+	}
+
+	if commit {
+		err = t.CommitEx(ctx)
+	} else {
+		err = t.RollbackEx(ctx)
+	}
+
+	// Failed commit is rolled back by the server
+	t.done(commit && err == nil, err)
+	return errors.Wrap(t.fail(ctx, err), emsg)
+}
+
+// prepare makes sure, that the cooked query is prepared on the dealer connection
+// The connection may be new or reconnected, so it is checked before each use
+// Queries, which were not cooked, are left as is
+func (t *tx) prepare(ctx context.Context, query string) error {
+	st, ok := t.c.stmts.get(query)
+	if !ok {
+		return unknown(query)
+	}
+
+	// It is a map lookup without a round trip, when already prepared
+	if _, err := t.Tx.PrepareEx(ctx, st.key, st.text, nil); err != nil {
+		return err
+	}
+
+	t.c.stmts.mark(t.cn, st.key)
+	return nil
+}
+
+// fetch loads rows into the collector, until it stops making new items
+// It returns a number of collected rows
+func fetch(rows *pgx.Rows, result Collector) (n int, err error) {
+	names := rows.FieldDescriptions()
+	places := make([]interface{}, len(names))
+
+	for rows.Next() {
+		item := result.NewItem()
+
+		if item == nil {
+			break
+		}
+
+		model := item.Extrude()
+
+		for i := range names {
+			places[i] = model.Translate(names[i].Name)
+		}
+
+		if err = rows.Scan(places...); err != nil {
+			return n, errors.Wrap(err, "scanning data row")
+		}
+
+		if err = item.Receive(model); err != nil {
+			return n, errors.Wrap(err, "receiving model")
+		}
+
+		if err = result.Collect(item); err != nil {
+			return n, errors.Wrap(err, "collecting item")
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+// oneItem is a collector for just one item
+// It remembers, when there are more rows
+type oneItem struct {
+	item Shaper
+	done bool
+	more bool
+}
+
+func (o *oneItem) NewItem() Shaper {
+	if o.done {
+		o.more = true
+		return nil
 	}
-	return errors.Wrap(t.Commit(), emsg)
+	o.done = true
+	return o.item
 }
+
+func (o *oneItem) Collect(item Shaper) error { return nil }
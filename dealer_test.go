@@ -1,11 +1,14 @@
 package wpgx_test
 
 import (
+	"context"
 	"database/sql"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx"
 	"github.com/pkg/errors"
 	"github.com/shestakovda/wpgx"
 	"github.com/stretchr/testify/assert"
@@ -100,6 +103,16 @@ func TestDealer(t *testing.T) {
 	assert.Equal(t, 1, nu.ID)
 	assert.Equal(t, "test", nu.Name)
 
+	err = d.Fetch(nu, sqlSelect, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", nu.Name)
+
+	err = d.Fetch(nu, sqlSelect, 2)
+	assert.Equal(t, wpgx.ErrNotFound, errors.Cause(err))
+
+	err = d.Fetch(nu, `SELECT * FROM users, generate_series(1, 2);`)
+	assert.Equal(t, wpgx.ErrTooManyRows, errors.Cause(err))
+
 	err = d.Save(new(user), sqlInsert, nil)
 	assert.EqualError(t, err, "executing query: ERROR: null value in column \"name\" violates not-null constraint (SQLSTATE 23502)")
 
@@ -107,10 +120,165 @@ func TestDealer(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "INSERT INTO users (name) VALUES ($1) RETURNING id;", string(dump))
 
-	dump, err = ioutil.ReadFile(filepath.Join(reserve, sqlInsert+"_c3b374cf5a76aa557c625f9eaa6e91f462b67fe2.json"))
+	dump, err = ioutil.ReadFile(filepath.Join(reserve, sqlInsert+"_61cc79eb78af932baf155ee64bb705507e14f6c5.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "[\n  {\n    \"name\": \"name\",\n    \"type\": \"null\",\n    \"value\": null\n  }\n]", string(dump))
+
+}
+
+func TestDealerContext(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	d, err := db.NewDealerContext(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, d)
+
+	err = d.Deal(nil, `SELECT 1;`)
+	assert.NoError(t, err)
+
+	err = d.Deal(nil, `SELECT pg_sleep(10);`)
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+
+	err = d.Deal(nil, `SELECT 1;`)
+	assert.Equal(t, wpgx.ErrConnClosed, errors.Cause(err))
+
+	err = d.Jail(true)
+	assert.Equal(t, wpgx.ErrConnClosed, errors.Cause(err))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	strings := make(wpgx.Strings, 0, 1)
+
+	err = db.DealContext(ctx, &strings, `SELECT 'test';`)
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+	assert.Len(t, strings, 0)
+
+	err = db.DealContext(context.Background(), &strings, `SELECT 'test';`)
+	assert.NoError(t, err)
+	assert.Len(t, strings, 1)
+}
+
+func TestDealerNest(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE nest_users (id serial PRIMARY KEY, name text not null);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE nest_users;`)
+		assert.NoError(t, err)
+	}()
+
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+	assert.NotNil(t, d)
+
+	err = d.Deal(nil, `INSERT INTO nest_users (name) VALUES ('outer');`)
 	assert.NoError(t, err)
-	assert.Equal(t, "{\n  \"name\": null\n}", string(dump))
 
+	n, err := d.Nest()
+	assert.NoError(t, err)
+	assert.NotNil(t, n)
+
+	_, err = d.Nest()
+	assert.Equal(t, wpgx.ErrNestedDealer, errors.Cause(err))
+
+	err = d.Jail(true)
+	assert.Equal(t, wpgx.ErrNestedDealer, errors.Cause(err))
+
+	err = d.Deal(nil, `INSERT INTO nest_users (name) VALUES ('parent');`)
+	assert.Equal(t, wpgx.ErrNestedDealer, errors.Cause(err))
+
+	err = n.Deal(nil, `INSERT INTO nest_users (name) VALUES ('inner');`)
+	assert.NoError(t, err)
+
+	err = n.Deal(nil, `INSERT INTO nest_users (name) VALUES (NULL);`)
+	assert.Error(t, err)
+
+	err = n.Jail(false)
+	assert.NoError(t, err)
+
+	err = n.Deal(nil, `SELECT 1;`)
+	assert.Equal(t, wpgx.ErrConnClosed, errors.Cause(err))
+
+	n, err = d.Nest()
+	assert.NoError(t, err)
+
+	err = n.Deal(nil, `INSERT INTO nest_users (name) VALUES ('released');`)
+	assert.NoError(t, err)
+
+	err = n.Jail(true)
+	assert.NoError(t, err)
+
+	err = d.Jail(true)
+	assert.NoError(t, err)
+
+	names := make(wpgx.Strings, 0, 2)
+	err = db.Deal(&names, `SELECT name FROM nest_users ORDER BY id;`)
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Strings{"outer", "released"}, names)
+
+	d, err = db.NewDealer()
+	assert.NoError(t, err)
+
+	n, err = d.Nest()
+	assert.NoError(t, err)
+
+	err = d.Jail(false)
+	assert.Equal(t, wpgx.ErrNestedDealer, errors.Cause(err))
+
+	err = n.Jail(false)
+	assert.Equal(t, wpgx.ErrConnClosed, errors.Cause(err))
+}
+
+func TestDealerMode(t *testing.T) {
+	db, err := wpgx.Connect(connStr, wpgx.TxDefaults(wpgx.IsoLevel(pgx.RepeatableRead)))
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	defer db.Close()
+
+	assert.Equal(t, pgx.TxOptions{IsoLevel: pgx.RepeatableRead}, db.Mode())
+
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+	assert.Equal(t, pgx.TxOptions{IsoLevel: pgx.RepeatableRead}, d.Mode())
+
+	level := make(wpgx.Strings, 0, 1)
+	err = d.Deal(&level, `SHOW transaction_isolation;`)
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Strings{"repeatable read"}, level)
+	assert.NoError(t, d.Jail(false))
+
+	d, err = db.NewDealer(wpgx.IsoLevel(pgx.Serializable), wpgx.ReadOnly(true), wpgx.Deferrable(true))
+	assert.NoError(t, err)
+	assert.Equal(t, pgx.TxOptions{
+		IsoLevel:       pgx.Serializable,
+		AccessMode:     pgx.ReadOnly,
+		DeferrableMode: pgx.Deferrable,
+	}, d.Mode())
+
+	level = make(wpgx.Strings, 0, 3)
+	err = d.Deal(&level, `SELECT current_setting(name) FROM unnest(ARRAY[
+		'transaction_isolation', 'transaction_read_only', 'transaction_deferrable'
+	]) AS name;`)
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Strings{"serializable", "on", "on"}, level)
+
+	err = d.Deal(nil, `CREATE TABLE mode_users (id serial PRIMARY KEY);`)
+	assert.Error(t, err)
+	assert.NoError(t, d.Jail(false))
+
+	_, err = db.NewDealer(wpgx.IsoLevel("chaos"))
+	assert.EqualError(t, err, "creating dealer: applying transaction options: unknown isolation level: chaos")
 }
 
 type user struct {
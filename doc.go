@@ -111,6 +111,9 @@ For performance issues we can to prepare statements but it is not required for s
         return err
     }
 
+Cooked statement is prepared on each connection before its first use there. Least recently
+used ones are evicted over wpgx.StatementLimit, or can be deallocated with db.Uncook.
+
 Now we can load user with ID=42:
 
     var user User
@@ -119,6 +122,20 @@ Now we can load user with ID=42:
         return err
     }
 
+Load leaves the user untouched, when there is no such one. Fetch is more strict:
+
+    err = db.Fetch(&user, sqlSelectUser, 42)
+    if errors.Cause(err) == wpgx.ErrNotFound {
+        return nil
+    }
+
+Database errors are classified with predicates, and wpgx.QueryError has the details:
+
+    if err = db.Save(user, sqlInsertUser, &ids); wpgx.IsUniqueViolation(err) {
+        qe, _ := wpgx.AsQueryError(err)
+        return fmt.Errorf("user exists: %s", qe.Constraint)
+    }
+
 Okay, but what if we need a list of users? No problems, let's implement Collector interface:
 
     type UserList []*User
@@ -185,6 +202,44 @@ For this task we have to prepare insert query with columns:
 
 Save uses Collector as result type because you may want to return many rows.
 
+Columns can be named right in the query with :name or @name placeholders instead:
+
+    sqlInsertUser, err := db.Cook(`
+    INSERT INTO users (name, role_id)
+    VALUES (:name, :role_id)
+    RETURNING id;
+    `)
+
+Or let Build generate insert, update, delete and upsert statements of the table:
+
+    crud, err := db.Build(wpgx.Table{
+        Name:      "users",
+        Columns:   []string{"name", "role_id"},
+        Key:       []string{"id"},
+        Returning: []string{"id"},
+    })
+    if err != nil {
+        return err
+    }
+
+    err = db.Save(user, crud.Insert, &ids)
+
+For a huge amount of items Copy is much faster. It reads items from a Provider
+and streams them with COPY command, using columns of the prepared query:
+
+    count, err := db.Copy(provider, "users", sqlInsertUser)
+    if err != nil {
+        return err
+    }
+
+When ReservePath is set, data of failed saves and copies is dumped there next
+to the query text. Replay runs it again, or use cmd/wpgx-replay for that:
+
+    count, err := wpgx.Replay(db, "./reserve")
+    if err != nil {
+        return err
+    }
+
 All examples above can be used in one transaction, that called Dealer.
 Typically Dealer can be used like this:
 
@@ -198,6 +253,256 @@ Typically Dealer can be used like this:
 
     // Doing something good stuff
 
+Or just let InTx do it. It also retries on serialization failures and deadlocks:
+
+    err = db.InTx(func(d wpgx.Dealer) error {
+        // Doing something good stuff
+        return nil
+    }, wpgx.Retries(5))
+
+Transaction mode can be set for all dealers in Connect, or for a single one:
+
+    db, err := wpgx.Connect(uri, wpgx.TxDefaults(wpgx.IsoLevel(pgx.RepeatableRead)))
+    if err != nil {
+        return err
+    }
+
+    report, err := db.NewDealer(wpgx.IsoLevel(pgx.Serializable), wpgx.ReadOnly(true), wpgx.Deferrable(true))
+    if err != nil {
+        return err
+    }
+
+Huge results can be read chunk by chunk with a server side cursor:
+
+    cur, err := d.Declare(false, sqlSelectUsers)
+    if err != nil {
+        return err
+    }
+    defer cur.Close()
+
+    for {
+        users := make(UserList, 0, 1000)
+        if count, err := cur.Next(&users, 1000); err != nil || count == 0 {
+            return err
+        }
+        // Export them somewhere
+    }
+
+API pages are better read by keys than with OFFSET. Pager gives tokens of the next
+and previous pages:
+
+    pager, err := db.Pager(`SELECT * FROM users WHERE role_id = $1`, 50, "created_at", "id")
+    if err != nil {
+        return err
+    }
+
+    page, err := pager.Next(db, &users, token, roleID)
+    if err != nil {
+        return err
+    }
+    // Give page.Next to the client, when page.HasNext
+
+Many small queries can be sent in one round trip with a Batch:
+
+    b := d.Batch()
+    b.Load(&user, sqlSelectUser, 42)
+    b.Deal(&users, sqlSelectUsers)
+
+    // Errors of each operation are in wpgx.BatchError
+    if err = b.Send(); err != nil {
+        return err
+    }
+
+Dealer can be nested with a savepoint. When the nested one fails,
+only its changes are rolled back:
+
+    n, err := d.Nest()
+    if err != nil {
+        return err
+    }
+
+    // Release savepoint or rollback to it
+    defer func(){ n.Jail(err == nil) }()
+
+Each method has a Context version. When the context is done, running statement
+is canceled and the transaction is rolled back:
+
+    ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+    defer cancel()
+
+    d, err := db.NewDealerContext(ctx)
+    if err != nil {
+        return err
+    }
+
+    // Dealer uses ctx in all methods without a context
+    if err = d.Load(&user, sqlSelectUser, 42); err != nil {
+        // errors.Cause(err) is context.DeadlineExceeded when time is out
+        return err
+    }
+
+When no separate model is need, plain structs with db tags can be used:
+
+    type Role struct {
+        ID   int    `db:"id"`
+        Name string `db:"name"`
+    }
+
+    role := new(Role)
+    roles := make([]*Role, 0, 10)
+
+    if err = db.Load(wpgx.Shape(role), `SELECT * FROM roles WHERE id = $1;`, 1); err != nil {
+        return err
+    }
+
+    if err = db.Deal(wpgx.Slice(&roles), `SELECT * FROM roles;`); err != nil {
+        return err
+    }
+
+With generics there is no need in a Collector for each model:
+
+    users, err := wpgx.Select[User](db, sqlSelectUsers)
+    if err != nil {
+        return err
+    }
+
+    user, err := wpgx.Get[User](db, sqlSelectUser, 42)
+    if err != nil {
+        return err
+    }
+
+    count, err := wpgx.Scalar[int](db, `SELECT count(*) FROM users;`)
+    if err != nil {
+        return err
+    }
+
+Notifications are sent by dealers only when the transaction commits,
+and received by subscriptions with their own connections:
+
+    sub, err := db.Listen("users")
+    if err != nil {
+        return err
+    }
+    defer sub.Close()
+
+    for note := range sub.Notifications() {
+        // Do something with note.Payload
+    }
+
+Records of pgx and wpgx itself, like reserve failures, are written to glog by default.
+Another logger can be set with structured fields, for example slog. The level is warning,
+unless LogLevel sets another one:
+
+    db, err := wpgx.Connect(connStr, wpgx.Logging(wpgx.Slog(slog.Default())), wpgx.LogLevel(pgx.LogLevelInfo))
+    if err != nil {
+        return err
+    }
+
+Slow Deal, Load and Save calls are logged as warnings with the statement key, SQL text,
+duration and argument types. With explain flag the record has the query plan as well:
+
+    db, err := wpgx.Connect(connStr, wpgx.SlowQuery(500*time.Millisecond, true))
+    if err != nil {
+        return err
+    }
+
+Reference data, that almost never changes, can be cached. Rows of the marked statement
+are kept by its arguments until they expire or a notification of the channel comes.
+Only Deal and Fetch of the connector and read only dealers use the cache, so the rows
+of uncommitted writes are never cached:
+
+    db, err := wpgx.Connect(connStr, wpgx.ResultCache(time.Hour, 64<<20, "roles_changed"))
+    if err != nil {
+        return err
+    }
+
+    if err = db.Cache(sqlSelectRoles); err != nil {
+        return err
+    }
+
+    // Loaded from the database the first time only
+    if err = db.Deal(wpgx.Slice(&roles), sqlSelectRoles); err != nil {
+        return err
+    }
+
+Read only dealers are balanced across replicas. Replicas, which are down or lagging
+behind, are out of rotation. Writes and Save always go to the primary:
+
+    db, err := wpgx.Connect(primaryStr, wpgx.Replica(replica1Str), wpgx.Replica(replica2Str))
+    if err != nil {
+        return err
+    }
+
+    // Connector methods go to a replica with the read only context
+    err = db.DealContext(wpgx.ReadOnlyContext(ctx), wpgx.Slice(&roles), sqlSelectRoles)
+
+    // This one sees all the changes, committed before it began
+    d, err := db.NewDealer(wpgx.ReadOnly(true), wpgx.ReadYourWrites(true))
+
+Router splits the data across several databases. Calls with the shard key in context
+go to its shard, others are run on every shard one by one. Statements are cooked on every
+shard even with the key:
+
+    db, err := wpgx.NewRouter(wpgx.HashShard, shard1, shard2)
+    if err != nil {
+        return err
+    }
+
+    // Cooked on every shard with the same key
+    if sqlSelectUser, err = db.Cook(`SELECT * FROM users WHERE id = $1;`); err != nil {
+        return err
+    }
+
+    err = db.LoadContext(wpgx.ShardContext(ctx, userID), wpgx.Shape(&user), sqlSelectUser, userID)
+
+Schema migrations are read from a file system, so they can be embedded. Files are named as
+"0001_create_users.up.sql" and "0001_create_users.down.sql". Only one instance migrates
+at a time, and edits of the applied migrations are detected by checksums:
+
+    //go:embed migrations
+    var migrations embed.FS
+
+    files, err := fs.Sub(migrations, "migrations")
+    if err != nil {
+        return err
+    }
+
+    m, err := wpgx.NewMigrator(db, files)
+    if err != nil {
+        return err
+    }
+
+    if _, err = m.Up(ctx); err != nil {
+        return err
+    }
+
+Each migration runs in its own transaction. Only the up script is in the checksum, so the
+down one can be fixed later. The file, which starts with "-- wpgx:no-transaction" line,
+runs without it, like CREATE INDEX CONCURRENTLY. The cmd/wpgx-migrate has up, down, status
+and redo actions.
+
+Pool waits, transactions and query timings by statement key are sent to an Observer.
+Metrics is the one, that counts them for Prometheus:
+
+    metrics := wpgx.NewMetrics()
+
+    db, err := wpgx.Connect(connStr, wpgx.Observe(metrics))
+    if err != nil {
+        return err
+    }
+
+    http.Handle("/metrics", metrics)
+
+Dealers can be traced as well. Each dealer is a span, and its Cook, Deal, Load and Save
+are child spans with the statement key, SQL text without literals and number of rows.
+Module wpgx/otel has an OpenTelemetry adapter, so wpgx itself does not depend on it.
+Recorder keeps spans for tests:
+
+    db, err := wpgx.Connect(connStr, wpgx.Trace(wpgxotel.New(otel.Tracer("wpgx"))))
+    if err != nil {
+        return err
+    }
+
 Good luck!
 */
 package wpgx
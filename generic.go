@@ -0,0 +1,85 @@
+package wpgx
+
+import "context"
+
+// Model is a constraint for a pointer to business type, which implements Shaper
+// It allows to use current models with generic functions without changes
+type Model[T any] interface {
+	*T
+	Shaper
+}
+
+// Select executes query and returns all items as a slice
+func Select[T any, P Model[T]](d Dealer, query string, args ...interface{}) ([]T, error) {
+	list := new(modelList[T, P])
+	err := d.Deal(list, query, args...)
+	return list.items, err
+}
+
+// SelectContext is a Select with a context
+func SelectContext[T any, P Model[T]](ctx context.Context, d Dealer, query string, args ...interface{}) ([]T, error) {
+	list := new(modelList[T, P])
+	err := d.DealContext(ctx, list, query, args...)
+	return list.items, err
+}
+
+// Get executes query and returns the first item
+func Get[T any, P Model[T]](d Dealer, query string, args ...interface{}) (item T, err error) {
+	err = d.Load(P(&item), query, args...)
+	return item, err
+}
+
+// GetContext is a Get with a context
+func GetContext[T any, P Model[T]](ctx context.Context, d Dealer, query string, args ...interface{}) (item T, err error) {
+	err = d.LoadContext(ctx, P(&item), query, args...)
+	return item, err
+}
+
+// Scalar executes query and returns the first column of the first row
+// Use sql.Null* types when the value may be NULL
+func Scalar[T any](d Dealer, query string, args ...interface{}) (T, error) {
+	item := new(scalarShaper[T])
+	err := d.Load(item, query, args...)
+	return item.value, err
+}
+
+// ScalarContext is a Scalar with a context
+func ScalarContext[T any](ctx context.Context, d Dealer, query string, args ...interface{}) (T, error) {
+	item := new(scalarShaper[T])
+	err := d.LoadContext(ctx, item, query, args...)
+	return item.value, err
+}
+
+type modelList[T any, P Model[T]] struct{ items []T }
+
+func (l *modelList[T, P]) NewItem() Shaper { return P(new(T)) }
+func (l *modelList[T, P]) Collect(item Shaper) error {
+	model, ok := item.(P)
+	if !ok || model == nil {
+		return ErrUnknownType
+	}
+	l.items = append(l.items, *model)
+	return nil
+}
+
+// scalarShaper keeps the first column only, columns are translated in the order of the row
+type scalarShaper[T any] struct {
+	value T
+	taken bool
+}
+
+func (s *scalarShaper[T]) Extrude() Translator {
+	s.taken = false
+	return s
+}
+
+func (s *scalarShaper[T]) Receive(model Translator) error { return nil }
+
+// Translate returns nil for other columns, so they are skipped by the scan
+func (s *scalarShaper[T]) Translate(name string) interface{} {
+	if s.taken {
+		return nil
+	}
+	s.taken = true
+	return &s.value
+}
@@ -0,0 +1,54 @@
+package wpgx_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneric(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE generic_users (id serial PRIMARY KEY, name text not null);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE generic_users;`)
+		assert.NoError(t, err)
+	}()
+
+	err = db.Deal(nil, `INSERT INTO generic_users (name) VALUES ('first'), ('second');`)
+	assert.NoError(t, err)
+
+	users, err := wpgx.Select[user](db, `SELECT * FROM generic_users ORDER BY id;`)
+	assert.NoError(t, err)
+	assert.Equal(t, []user{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}}, users)
+
+	users, err = wpgx.Select[user](db, `SELECT * FROM generic_users WHERE id > $1;`, 2)
+	assert.NoError(t, err)
+	assert.Len(t, users, 0)
+
+	u, err := wpgx.Get[user](db, `SELECT * FROM generic_users WHERE id = $1;`, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, user{ID: 2, Name: "second"}, u)
+
+	count, err := wpgx.Scalar[int](db, `SELECT count(*) FROM generic_users;`)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Other columns are ignored
+	count, err = wpgx.Scalar[int](db, `SELECT 1 AS a, 2 AS b;`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	name, err := wpgx.Scalar[sql.NullString](db, `SELECT NULL::text;`)
+	assert.NoError(t, err)
+	assert.False(t, name.Valid)
+
+	_, err = wpgx.Select[user](db, `SELECT FROM WHERE;`)
+	assert.EqualError(t, err, "selecting data: ERROR: syntax error at or near \"WHERE\" (SQLSTATE 42601)")
+}
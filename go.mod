@@ -0,0 +1,18 @@
+module github.com/shestakovda/wpgx
+
+go 1.21
+
+require (
+	github.com/golang/glog v1.2.5
+	github.com/jackc/pgx v3.6.2+incompatible
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,70 @@
+package wpgx
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// retryable checks for serialization failure or deadlock
+func retryable(err error) bool {
+	return IsSerializationFailure(err) || IsDeadlock(err)
+}
+
+func (c *conn) InTx(fn func(Dealer) error, options ...func(*TxConfig) error) error {
+	return c.InTxContext(context.Background(), fn, options...)
+}
+
+func (c *conn) InTxContext(ctx context.Context, fn func(Dealer) error, options ...func(*TxConfig) error) (err error) {
+	var cfg *TxConfig
+	const emsg = "running transaction"
+
+	if cfg, err = c.txConfig(ctx, options); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+
+	delay := cfg.Backoff
+
+	for try := 0; ; try++ {
+		if err = c.runTx(ctx, cfg, fn); err == nil || try >= cfg.Retries || !retryable(err) {
+			return errors.Wrap(err, emsg)
+		}
+
+		// Full jitter, so competing transactions are spread out
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), emsg)
+		case <-time.After(time.Duration(rand.Int63n(int64(delay)) + 1)):
+		}
+
+		if delay *= 2; delay > cfg.MaxBackoff {
+			delay = cfg.MaxBackoff
+		}
+	}
+}
+
+func (c *conn) runTx(ctx context.Context, cfg *TxConfig, fn func(Dealer) error) (err error) {
+	var d *tx
+
+	if d, err = c.begin(ctx, cfg); err != nil {
+		return
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			d.Jail(false)
+			panic(p)
+		}
+
+		if err != nil {
+			d.Jail(false)
+			return
+		}
+
+		err = d.Jail(true)
+	}()
+
+	return fn(d)
+}
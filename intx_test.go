@@ -0,0 +1,72 @@
+package wpgx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInTx(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE intx_users (id serial PRIMARY KEY, name text not null);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE intx_users;`)
+		assert.NoError(t, err)
+	}()
+
+	err = db.InTx(func(d wpgx.Dealer) error {
+		return d.Deal(nil, `INSERT INTO intx_users (name) VALUES ('commit');`)
+	})
+	assert.NoError(t, err)
+
+	fail := errors.New("test")
+	err = db.InTx(func(d wpgx.Dealer) error {
+		if err := d.Deal(nil, `INSERT INTO intx_users (name) VALUES ('error');`); err != nil {
+			return err
+		}
+		return fail
+	})
+	assert.Equal(t, fail, errors.Cause(err))
+
+	assert.Panics(t, func() {
+		db.InTx(func(d wpgx.Dealer) error {
+			d.Deal(nil, `INSERT INTO intx_users (name) VALUES ('panic');`)
+			panic("test")
+		})
+	})
+
+	tries := 0
+	err = db.InTx(func(d wpgx.Dealer) error {
+		if tries++; tries < 3 {
+			return pgx.PgError{Code: "40001"}
+		}
+		return d.Deal(nil, `INSERT INTO intx_users (name) VALUES ('retry');`)
+	}, wpgx.Backoff(time.Millisecond, 2*time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, tries)
+
+	tries = 0
+	err = db.InTx(func(d wpgx.Dealer) error {
+		tries++
+		return pgx.PgError{Code: "40P01"}
+	}, wpgx.Retries(1), wpgx.Backoff(time.Millisecond, time.Millisecond))
+	assert.Equal(t, pgx.PgError{Code: "40P01"}, errors.Cause(err))
+	assert.Equal(t, 2, tries)
+
+	err = db.InTx(nil, wpgx.Backoff(0, 0))
+	assert.EqualError(t, err, "running transaction: applying transaction options: invalid backoff delays")
+
+	names := make(wpgx.Strings, 0, 2)
+	err = db.Deal(&names, `SELECT name FROM intx_users ORDER BY id;`)
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Strings{"commit", "retry"}, names)
+}
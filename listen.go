@@ -0,0 +1,200 @@
+package wpgx
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// Reconnect delays of the subscription connection
+const (
+	listenMinDelay = 100 * time.Millisecond
+	listenMaxDelay = 10 * time.Second
+)
+
+// Subscription delivers notifications of the channel. It uses own connection outside the pool
+// and subscribes again after reconnect. Notifications sent while reconnecting are lost, so
+// after reconnect an empty notification with zero PID is delivered
+//
+// Notifications returns a Go channel with notifications. It is closed after Close
+//
+// Close stops listening and closes the connection
+type Subscription interface {
+	Notifications() <-chan *pgx.Notification
+	Close() error
+}
+
+type subscription struct {
+	c       *conn
+	channel string
+	notes   chan *pgx.Notification
+	done    chan struct{}
+	cancel  context.CancelFunc
+	once    sync.Once
+}
+
+func (c *conn) Listen(channel string) (Subscription, error) {
+	const emsg = "listening channel"
+
+	if err := c.ready(); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	s := &subscription{
+		c:       c,
+		channel: channel,
+		notes:   make(chan *pgx.Notification, 64),
+		done:    make(chan struct{}),
+	}
+
+	cn, err := s.connect()
+	if err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+
+	c.Lock()
+	c.subscriptions[s] = struct{}{}
+	c.Unlock()
+
+	go s.run(ctx, cn)
+	return s, nil
+}
+
+func (s *subscription) Notifications() <-chan *pgx.Notification { return s.notes }
+
+func (s *subscription) Close() error {
+	s.once.Do(func() {
+		s.cancel()
+		<-s.done
+
+		s.c.Lock()
+		delete(s.c.subscriptions, s)
+		s.c.Unlock()
+	})
+	return nil
+}
+
+func (s *subscription) connect() (cn *pgx.Conn, err error) {
+	if cn, err = pgx.Connect(s.c.connConfig); err != nil {
+		return nil, errors.Wrap(err, "connecting")
+	}
+
+	if err = cn.Listen(s.channel); err != nil {
+		cn.Close()
+		return nil, errors.Wrap(err, "subscribing")
+	}
+
+	return cn, nil
+}
+
+func (s *subscription) run(ctx context.Context, cn *pgx.Conn) {
+	defer close(s.done)
+	defer close(s.notes)
+
+	delay := listenMinDelay
+
+	for {
+		note, err := cn.WaitForNotification(ctx)
+
+		if err == nil {
+			delay = listenMinDelay
+
+			select {
+			case s.notes <- note:
+				continue
+			case <-ctx.Done():
+			}
+		}
+
+		if ctx.Err() != nil {
+			cn.Close()
+			return
+		}
+
+		// Error of the alive connection may repeat at once, so the wait is delayed
+		if cn.IsAlive() {
+			select {
+			case <-ctx.Done():
+				cn.Close()
+				return
+			case <-time.After(delay):
+			}
+
+			if delay *= 2; delay > listenMaxDelay {
+				delay = listenMaxDelay
+			}
+			continue
+		}
+
+		cn.Close()
+
+		if cn = s.reconnect(ctx); cn == nil {
+			return
+		}
+
+		select {
+		case s.notes <- &pgx.Notification{Channel: s.channel}:
+		case <-ctx.Done():
+			cn.Close()
+			return
+		}
+	}
+}
+
+// reconnect tries to connect again until the context is done
+func (s *subscription) reconnect(ctx context.Context) *pgx.Conn {
+	delay := listenMinDelay
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		if cn, err := s.connect(); err == nil {
+			return cn
+		}
+
+		if delay *= 2; delay > listenMaxDelay {
+			delay = listenMaxDelay
+		}
+	}
+}
+
+func (t *tx) Notify(channel, payload string) error {
+	return t.NotifyContext(t.ctx, channel, payload)
+}
+
+func (t *tx) NotifyContext(ctx context.Context, channel, payload string) (err error) {
+	const emsg = "sending notification"
+
+	if err = t.ready(); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+
+	_, err = t.ExecEx(ctx, "SELECT pg_notify($1, $2);", nil, channel, payload)
+	return errors.Wrap(t.fail(ctx, err), emsg)
+}
+
+func (c *conn) Notify(channel, payload string) error {
+	return c.NotifyContext(context.Background(), channel, payload)
+}
+
+func (c *conn) NotifyContext(ctx context.Context, channel, payload string) (err error) {
+	var d Dealer
+	const emsg = "sending notification"
+
+	if d, err = c.NewDealerContext(ctx); err != nil {
+		return errors.Wrap(err, emsg)
+	}
+	defer func() { d.Jail(err == nil) }()
+
+	return d.NotifyContext(ctx, channel, payload)
+}
@@ -0,0 +1,74 @@
+package wpgx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListen(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	defer db.Close()
+
+	sub, err := db.Listen("wpgx_test")
+	assert.NoError(t, err)
+	assert.NotNil(t, sub)
+
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+	assert.NoError(t, d.Notify("wpgx_test", "rollback"))
+	assert.NoError(t, d.Jail(false))
+
+	d, err = db.NewDealer()
+	assert.NoError(t, err)
+	assert.NoError(t, d.Notify("wpgx_test", "commit"))
+	assert.NoError(t, d.Jail(true))
+
+	assert.NoError(t, db.Notify("wpgx_test", "connector"))
+
+	for _, payload := range []string{"commit", "connector"} {
+		select {
+		case note := <-sub.Notifications():
+			assert.Equal(t, "wpgx_test", note.Channel)
+			assert.Equal(t, payload, note.Payload)
+		case <-time.After(5 * time.Second):
+			t.Fatal("notification timeout")
+		}
+	}
+
+	select {
+	case note := <-sub.Notifications():
+		t.Fatalf("unexpected notification: %+v", note)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Kill the listening connection, so subscription has to reconnect
+	err = db.Deal(nil, `SELECT pg_terminate_backend(pid) FROM pg_stat_activity
+		WHERE query LIKE 'listen%' AND pid <> pg_backend_pid();`)
+	assert.NoError(t, err)
+
+	select {
+	case note := <-sub.Notifications():
+		assert.Equal(t, uint32(0), note.PID)
+		assert.Equal(t, "", note.Payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconnect timeout")
+	}
+
+	assert.NoError(t, db.Notify("wpgx_test", "again"))
+
+	select {
+	case note := <-sub.Notifications():
+		assert.Equal(t, "again", note.Payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification timeout")
+	}
+
+	assert.NoError(t, sub.Close())
+	_, ok := <-sub.Notifications()
+	assert.False(t, ok)
+}
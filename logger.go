@@ -1,23 +1,115 @@
 package wpgx
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
 	"github.com/golang/glog"
 	"github.com/jackc/pgx"
 )
 
-const logtpl = "wpgx: %s %+v"
+// Field is a structured value of the log record
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger receives log records of pgx and wpgx itself, like reserve failures
+// Levels are the same as of pgx, fields are sorted by keys
+type Logger interface {
+	Log(level pgx.LogLevel, msg string, fields []Field)
+}
 
-type logger struct{}
+// Glog is a logger, which writes records to glog as "wpgx: msg key=value"
+// godoc: https://godoc.org/github.com/golang/glog
+func Glog() Logger { return glogLogger{} }
+
+// Slog is a logger, which writes records to the slog logger with attributes
+func Slog(l *slog.Logger) Logger { return slogLogger{l: l} }
+
+// Nop is a logger, which drops all the records
+func Nop() Logger { return nopLogger{} }
+
+type glogLogger struct{}
+
+func (glogLogger) Log(level pgx.LogLevel, msg string, fields []Field) {
+	var b strings.Builder
+
+	b.WriteString("wpgx: ")
+	b.WriteString(msg)
+
+	for i := range fields {
+		fmt.Fprintf(&b, " %s=%+v", fields[i].Key, fields[i].Value)
+	}
 
-func (l *logger) Log(level pgx.LogLevel, msg string, data map[string]interface{}) {
 	switch level {
 	case pgx.LogLevelNone:
 		return
 	case pgx.LogLevelError:
-		glog.Errorf(logtpl, msg, data)
+		glog.Error(b.String())
 	case pgx.LogLevelWarn:
-		glog.Warningf(logtpl, msg, data)
+		glog.Warning(b.String())
 	default:
-		glog.Infof(logtpl, msg, data)
+		glog.Info(b.String())
+	}
+}
+
+type slogLogger struct{ l *slog.Logger }
+
+func (s slogLogger) Log(level pgx.LogLevel, msg string, fields []Field) {
+	var lvl slog.Level
+
+	switch level {
+	case pgx.LogLevelNone:
+		return
+	case pgx.LogLevelError:
+		lvl = slog.LevelError
+	case pgx.LogLevelWarn:
+		lvl = slog.LevelWarn
+	case pgx.LogLevelInfo:
+		lvl = slog.LevelInfo
+	default:
+		lvl = slog.LevelDebug
+	}
+
+	attrs := make([]slog.Attr, len(fields))
+	for i := range fields {
+		attrs[i] = slog.Any(fields[i].Key, fields[i].Value)
+	}
+
+	s.l.LogAttrs(context.Background(), lvl, msg, attrs...)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(pgx.LogLevel, string, []Field) {}
+
+// logger passes pgx records to the wpgx logger with the data map as fields
+type logger struct{ Logger }
+
+func (l *logger) Log(level pgx.LogLevel, msg string, data map[string]interface{}) {
+	l.Logger.Log(level, msg, fields(data))
+}
+
+// fields makes a list of fields, sorted by keys
+func fields(data map[string]interface{}) []Field {
+	list := make([]Field, 0, len(data))
+
+	for key, value := range data {
+		list = append(list, Field{Key: key, Value: value})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// log writes wpgx own records, when the level is enabled
+func (c *conn) log(level pgx.LogLevel, msg string, fields ...Field) {
+	if c.logger == nil || level == pgx.LogLevelNone || level > c.logLevel {
+		return
 	}
+	c.logger.Log(level, msg, fields)
 }
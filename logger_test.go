@@ -1,19 +1,42 @@
 package wpgx
 
 import (
+	"bytes"
+	"log/slog"
 	"testing"
 
 	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLogger(t *testing.T) {
 	const msg = "test"
 	var data = map[string]interface{}{"key": "value"}
 
-	l := new(logger)
+	for _, target := range []Logger{Glog(), Nop()} {
+		l := &logger{target}
+		l.Log(pgx.LogLevelNone, msg, data)
+		l.Log(pgx.LogLevelError, msg, data)
+		l.Log(pgx.LogLevelWarn, msg, data)
+		l.Log(pgx.LogLevelInfo, msg, data)
+		l.Log(pgx.LogLevelDebug, msg, data)
+	}
+
+	buf := new(bytes.Buffer)
+	l := &logger{Slog(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))}
+
 	l.Log(pgx.LogLevelNone, msg, data)
-	l.Log(pgx.LogLevelError, msg, data)
-	l.Log(pgx.LogLevelWarn, msg, data)
-	l.Log(pgx.LogLevelInfo, msg, data)
-	l.Log(pgx.LogLevelDebug, msg, data)
+	assert.Empty(t, buf.String())
+
+	l.Log(pgx.LogLevelWarn, msg, map[string]interface{}{"sql": "SELECT 1", "args": 2})
+	assert.Contains(t, buf.String(), `level=WARN msg=test args=2 sql="SELECT 1"`)
+
+	buf.Reset()
+	c := &conn{logger: l.Logger, logLevel: pgx.LogLevelWarn}
+
+	c.log(pgx.LogLevelInfo, msg)
+	assert.Empty(t, buf.String())
+
+	c.log(pgx.LogLevelError, "reserving data", Field{"key", "k1"}, Field{"rows", 3})
+	assert.Contains(t, buf.String(), `level=ERROR msg="reserving data" key=k1 rows=3`)
 }
@@ -0,0 +1,186 @@
+package wpgx
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are default histogram buckets in seconds, the same as of Prometheus client
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics is an observer, which counts events in memory
+// It is a http.Handler as well, so it can be served as /metrics in Prometheus text format
+//
+// Queries are labeled with their statement keys, so the cardinality is
+// limited by the registry. All the queries, that were not cooked, share the RawKey
+type Metrics struct {
+	mu      sync.Mutex
+	buckets []float64
+	tx      map[string]uint64
+	query   map[string]*histogram
+	rows    map[string]uint64
+	errs    map[[2]string]uint64
+	acquire *histogram
+	flight  int64
+}
+
+// histogram counts observations of every bucket separately, they are summed on write
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewMetrics makes metrics with histogram buckets in seconds, DefBuckets by default
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	m := &Metrics{
+		buckets: buckets,
+		tx:      make(map[string]uint64),
+		query:   make(map[string]*histogram),
+		rows:    make(map[string]uint64),
+		errs:    make(map[[2]string]uint64),
+	}
+	m.acquire = m.histogram()
+	return m
+}
+
+func (m *Metrics) histogram() *histogram {
+	return &histogram{counts: make([]uint64, len(m.buckets))}
+}
+
+func (m *Metrics) observe(h *histogram, sec float64) {
+	h.count++
+	h.sum += sec
+
+	if i := sort.SearchFloat64s(m.buckets, sec); i < len(h.counts) {
+		h.counts[i]++
+	}
+}
+
+func (m *Metrics) Observe(e Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch e.Kind {
+	case EventAcquire:
+		m.observe(m.acquire, e.Duration.Seconds())
+	case EventBegin, EventCommit, EventRollback:
+		m.tx[e.Kind.String()]++
+	case EventQueryStart:
+		m.flight++
+	case EventQueryFinish:
+		m.flight--
+
+		h, ok := m.query[e.Key]
+		if !ok {
+			h = m.histogram()
+			m.query[e.Key] = h
+		}
+
+		m.observe(h, e.Duration.Seconds())
+		m.rows[e.Key] += uint64(e.Rows)
+
+		if e.Class != "" {
+			m.errs[[2]string{e.Key, e.Class}]++
+		}
+	}
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes all the metrics in Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := new(strings.Builder)
+
+	b.WriteString("# HELP wpgx_transactions_total Number of begun, committed and rolled back dealers.\n")
+	b.WriteString("# TYPE wpgx_transactions_total counter\n")
+	for _, name := range []string{"begin", "commit", "rollback"} {
+		fmt.Fprintf(b, "wpgx_transactions_total{event=%q} %d\n", name, m.tx[name])
+	}
+
+	b.WriteString("# HELP wpgx_pool_acquire_seconds Wait time for a pool connection.\n")
+	b.WriteString("# TYPE wpgx_pool_acquire_seconds histogram\n")
+	m.write(b, "wpgx_pool_acquire_seconds", "", m.acquire)
+
+	b.WriteString("# HELP wpgx_queries_in_flight Number of running queries.\n")
+	b.WriteString("# TYPE wpgx_queries_in_flight gauge\n")
+	fmt.Fprintf(b, "wpgx_queries_in_flight %d\n", m.flight)
+
+	keys := make([]string, 0, len(m.query))
+	for key := range m.query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	b.WriteString("# HELP wpgx_query_duration_seconds Query time by statement key.\n")
+	b.WriteString("# TYPE wpgx_query_duration_seconds histogram\n")
+	for _, key := range keys {
+		m.write(b, "wpgx_query_duration_seconds", "key="+strconv.Quote(key), m.query[key])
+	}
+
+	b.WriteString("# HELP wpgx_query_rows_total Number of scanned or affected rows by statement key.\n")
+	b.WriteString("# TYPE wpgx_query_rows_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(b, "wpgx_query_rows_total{key=%q} %d\n", key, m.rows[key])
+	}
+
+	errs := make([][2]string, 0, len(m.errs))
+	for pair := range m.errs {
+		errs = append(errs, pair)
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i][0] != errs[j][0] {
+			return errs[i][0] < errs[j][0]
+		}
+		return errs[i][1] < errs[j][1]
+	})
+
+	b.WriteString("# HELP wpgx_query_errors_total Number of failed queries by statement key and error class.\n")
+	b.WriteString("# TYPE wpgx_query_errors_total counter\n")
+	for _, pair := range errs {
+		fmt.Fprintf(b, "wpgx_query_errors_total{key=%q,class=%q} %d\n", pair[0], pair[1], m.errs[pair])
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// write makes cumulative buckets, sum and count lines of the histogram
+func (m *Metrics) write(b *strings.Builder, name, labels string, h *histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+
+	var total uint64
+
+	for i, le := range m.buckets {
+		total += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{%s%sle=%q} %d\n", name, labels, sep, strconv.FormatFloat(le, 'g', -1, 64), total)
+	}
+	fmt.Fprintf(b, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(b, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(b, "%s_count%s %d\n", name, labels, h.count)
+}
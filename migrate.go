@@ -0,0 +1,401 @@
+package wpgx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// ErrMigrationChanged occurs when the file of the applied migration is edited
+var ErrMigrationChanged = errors.New("applied migration is changed")
+
+// NoTxMark is the first line of the migration file, which runs outside of a transaction
+// It is for statements like CREATE INDEX CONCURRENTLY. The file should have one statement,
+// because several statements of one query are in the implicit transaction anyway
+const NoTxMark = "-- wpgx:no-transaction"
+
+// MigrationTable is the history table of the applied migrations
+const MigrationTable = "wpgx_migrations"
+
+// migrationLock is the advisory lock key of the migrations, it is FNV-1a hash of the table name
+const migrationLock int64 = 0x66ffdf28a5f3e7b7
+
+// Script is the SQL text of one migration direction
+type Script struct {
+	SQL  string
+	NoTx bool
+}
+
+// Migration is a pair of up and down scripts of the version
+// Checksum is a SHA-256 of the up script, it is saved to the history when applied
+// The down script is not in the checksum, so it can be fixed after the migration is applied
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       Script
+	Down     Script
+	Checksum string
+}
+
+// MigrationStatus is a migration with its history record
+// Changed is set, when the checksum of the applied migration is not the same as of the file
+// Missing is set, when the migration is applied, but there is no file of it
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Changed   bool
+	Missing   bool
+}
+
+// LoadMigrations reads the migrations from the directory of the file system
+// Files are named as "<version>_<name>.up.sql" and "<version>_<name>.down.sql",
+// the down one is optional. Migrations are sorted by version
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	const emsg = "loading migrations"
+
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		name := file.Name()
+
+		var up bool
+		var base string
+
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			up, base = true, strings.TrimSuffix(name, ".up.sql")
+		case strings.HasSuffix(name, ".down.sql"):
+			base = strings.TrimSuffix(name, ".down.sql")
+		default:
+			continue
+		}
+
+		num := base
+		if i := strings.IndexByte(base, '_'); i >= 0 {
+			num, base = base[:i], base[i+1:]
+		} else {
+			base = ""
+		}
+
+		version, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, errors.New("invalid migration version: " + name)
+		}
+
+		text, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, errors.Wrap(err, emsg)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: base}
+			byVersion[version] = m
+		} else if m.Name != base {
+			return nil, errors.New("migration names of version are not the same: " + name)
+		}
+
+		script := Script{SQL: string(text), NoTx: strings.HasPrefix(string(text), NoTxMark)}
+
+		if up {
+			sum := sha256.Sum256(text)
+			m.Up, m.Checksum = script, hex.EncodeToString(sum[:])
+		} else {
+			m.Down = script
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+
+	for _, m := range byVersion {
+		if m.Up.SQL == "" {
+			return nil, errors.New("no up migration of version " + strconv.FormatInt(m.Version, 10))
+		}
+		list = append(list, *m)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Migrator applies and reverts migrations of the database
+// Only one migrator of the cluster runs at a time, others wait for the advisory lock
+//
+// Each migration runs in its own dealer together with the history record on the locked
+// connection, so the migrator needs just one connection of the pool. The ones marked with
+// NoTxMark run without a transaction
+type Migrator struct {
+	c    *conn
+	list []Migration
+}
+
+// NewMigrator makes a migrator of the connector with migrations from the root of file system
+// Use fs.Sub to take a subdirectory of the embedded files. Connector should be made by Connect
+func NewMigrator(db Connector, fsys fs.FS) (*Migrator, error) {
+	c, ok := db.(*conn)
+	if !ok {
+		return nil, errors.New("migrator needs a connector of Connect")
+	}
+
+	if err := c.ready(); err != nil {
+		return nil, errors.Wrap(err, "creating migrator")
+	}
+
+	list, err := LoadMigrations(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{c: c, list: list}, nil
+}
+
+// Migrations returns the loaded migrations sorted by version
+func (m *Migrator) Migrations() []Migration { return m.list }
+
+// Up applies all migrations, which are not applied yet. It returns a number of applied ones
+func (m *Migrator) Up(ctx context.Context) (n int, err error) {
+	err = m.locked(ctx, true, func(cn *pgx.Conn, list []MigrationStatus) error {
+		for i := range list {
+			if list[i].Applied {
+				continue
+			}
+
+			if err := m.up(ctx, cn, &list[i].Migration); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, errors.Wrap(err, "migrating up")
+}
+
+// Down reverts the last applied migrations one by one. It returns a number of reverted ones
+func (m *Migrator) Down(ctx context.Context, steps int) (n int, err error) {
+	err = m.locked(ctx, true, func(cn *pgx.Conn, list []MigrationStatus) error {
+		for i := len(list) - 1; i >= 0 && n < steps; i-- {
+			if !list[i].Applied {
+				continue
+			}
+
+			if err := m.down(ctx, cn, &list[i]); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, errors.Wrap(err, "migrating down")
+}
+
+// Redo reverts the last applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) error {
+	err := m.locked(ctx, true, func(cn *pgx.Conn, list []MigrationStatus) error {
+		for i := len(list) - 1; i >= 0; i-- {
+			if !list[i].Applied {
+				continue
+			}
+
+			if err := m.down(ctx, cn, &list[i]); err != nil {
+				return err
+			}
+			return m.up(ctx, cn, &list[i].Migration)
+		}
+		return errors.New("no applied migrations")
+	})
+	return errors.Wrap(err, "redoing migration")
+}
+
+// Status returns all the migrations of the files and the history, sorted by version
+func (m *Migrator) Status(ctx context.Context) (list []MigrationStatus, err error) {
+	err = m.locked(ctx, false, func(cn *pgx.Conn, all []MigrationStatus) error {
+		list = all
+		return nil
+	})
+	return list, errors.Wrap(err, "getting migration status")
+}
+
+// locked runs the function, while the advisory lock is held on the connection
+// With the check flag checksums of the applied migrations are checked first, so nothing runs,
+// when any of them is edited
+func (m *Migrator) locked(ctx context.Context, check bool, fn func(*pgx.Conn, []MigrationStatus) error) (err error) {
+	if err = m.c.ready(); err != nil {
+		return err
+	}
+
+	cn, err := m.c.pool.AcquireEx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "acquiring connection")
+	}
+	defer m.c.pool.Release(cn)
+
+	if _, err = cn.ExecEx(ctx, "SELECT pg_advisory_lock($1);", nil, migrationLock); err != nil {
+		return errors.Wrap(err, "locking migrations")
+	}
+
+	defer func() {
+		// Context may be done already, but the lock should be released anyway
+		if _, uerr := cn.Exec("SELECT pg_advisory_unlock($1);", migrationLock); uerr != nil && err == nil {
+			err = errors.Wrap(uerr, "unlocking migrations")
+		}
+	}()
+
+	table := pgx.Identifier{MigrationTable}.Sanitize()
+
+	if _, err = cn.ExecEx(ctx, `CREATE TABLE IF NOT EXISTS `+table+` (
+		version    bigint      NOT NULL PRIMARY KEY,
+		name       text        NOT NULL,
+		checksum   text        NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	);`, nil); err != nil {
+		return errors.Wrap(err, "creating migration table")
+	}
+
+	list, err := m.status(ctx, cn)
+	if err != nil {
+		return err
+	}
+
+	for i := range list {
+		if check && list[i].Changed {
+			return errors.Wrapf(ErrMigrationChanged, "version %d", list[i].Version)
+		}
+	}
+
+	return fn(cn, list)
+}
+
+// status merges the history with the files
+func (m *Migrator) status(ctx context.Context, cn *pgx.Conn) ([]MigrationStatus, error) {
+	const emsg = "reading migration history"
+
+	table := pgx.Identifier{MigrationTable}.Sanitize()
+
+	rows, err := cn.QueryEx(ctx, `SELECT version, name, checksum, applied_at FROM `+table+`;`, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+	defer rows.Close()
+
+	byVersion := make(map[int64]*MigrationStatus, len(m.list))
+	list := make([]MigrationStatus, len(m.list))
+
+	for i := range m.list {
+		list[i].Migration = m.list[i]
+		byVersion[m.list[i].Version] = &list[i]
+	}
+
+	var missing []MigrationStatus
+
+	for rows.Next() {
+		var s MigrationStatus
+
+		if err = rows.Scan(&s.Version, &s.Name, &s.Checksum, &s.AppliedAt); err != nil {
+			return nil, errors.Wrap(err, emsg)
+		}
+
+		s.Applied = true
+
+		if f, ok := byVersion[s.Version]; ok {
+			f.Applied, f.AppliedAt, f.Changed = true, s.AppliedAt, f.Checksum != s.Checksum
+		} else {
+			s.Missing = true
+			missing = append(missing, s)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	list = append(list, missing...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func (m *Migrator) up(ctx context.Context, cn *pgx.Conn, mg *Migration) error {
+	table := pgx.Identifier{MigrationTable}.Sanitize()
+	query := `INSERT INTO ` + table + ` (version, name, checksum) VALUES ($1, $2, $3);`
+
+	err := m.run(ctx, cn, mg.Up, query, mg.Version, mg.Name, mg.Checksum)
+	if err != nil {
+		return errors.Wrapf(err, "applying migration %d", mg.Version)
+	}
+
+	m.c.log(pgx.LogLevelInfo, "migration applied", Field{"name", mg.Name}, Field{"version", mg.Version})
+	return nil
+}
+
+func (m *Migrator) down(ctx context.Context, cn *pgx.Conn, s *MigrationStatus) error {
+	if s.Missing {
+		return errors.Errorf("no file of migration %d", s.Version)
+	}
+
+	if s.Down.SQL == "" {
+		return errors.Errorf("no down migration of version %d", s.Version)
+	}
+
+	table := pgx.Identifier{MigrationTable}.Sanitize()
+	query := `DELETE FROM ` + table + ` WHERE version = $1;`
+
+	if err := m.run(ctx, cn, s.Down, query, s.Version); err != nil {
+		return errors.Wrapf(err, "reverting migration %d", s.Version)
+	}
+
+	m.c.log(pgx.LogLevelInfo, "migration reverted", Field{"name", s.Name}, Field{"version", s.Version})
+	return nil
+}
+
+// run executes the script and the history query in a dealer on the locked connection
+// Script without a transaction is executed as is
+func (m *Migrator) run(ctx context.Context, cn *pgx.Conn, script Script, query string, args ...interface{}) (err error) {
+	if script.NoTx {
+		if _, err := cn.ExecEx(ctx, script.SQL, nil); err != nil {
+			return errors.Wrap(err, "executing script")
+		}
+
+		_, err = cn.ExecEx(ctx, query, nil, args...)
+		return errors.Wrap(err, "writing migration history")
+	}
+
+	cfg, err := m.c.txConfig(ctx, []func(*TxConfig) error{ReadOnly(false)})
+	if err != nil {
+		return err
+	}
+
+	d, err := m.c.beginOn(ctx, cn, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if jerr := d.JailContext(ctx, err == nil); jerr != nil && err == nil {
+			err = errors.Wrap(jerr, "committing migration")
+		}
+	}()
+
+	if err = d.DealContext(ctx, nil, script.SQL); err != nil {
+		return errors.Wrap(err, "executing script")
+	}
+
+	err = d.DealContext(ctx, nil, query, args...)
+	return errors.Wrap(err, "writing migration history")
+}
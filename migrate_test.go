@@ -0,0 +1,125 @@
+package wpgx_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func migrationFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_items.up.sql":   {Data: []byte(`CREATE TABLE migrate_items (id int PRIMARY KEY, name text);`)},
+		"0001_create_items.down.sql": {Data: []byte(`DROP TABLE migrate_items;`)},
+		"0002_index_name.up.sql": {Data: []byte(wpgx.NoTxMark + "\n" +
+			`CREATE INDEX CONCURRENTLY migrate_items_name ON migrate_items (name);`)},
+		"0002_index_name.down.sql": {Data: []byte(wpgx.NoTxMark + "\n" +
+			`DROP INDEX CONCURRENTLY migrate_items_name;`)},
+		"README.md": {Data: []byte(`not a migration`)},
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	list, err := wpgx.LoadMigrations(migrationFiles(), ".")
+	assert.NoError(t, err)
+
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, int64(1), list[0].Version)
+		assert.Equal(t, "create_items", list[0].Name)
+		assert.False(t, list[0].Up.NoTx)
+		assert.Len(t, list[0].Checksum, 64)
+		assert.Equal(t, int64(2), list[1].Version)
+		assert.True(t, list[1].Up.NoTx)
+		assert.True(t, list[1].Down.NoTx)
+	}
+
+	_, err = wpgx.LoadMigrations(fstest.MapFS{"x_items.up.sql": {}}, ".")
+	assert.EqualError(t, err, "invalid migration version: x_items.up.sql")
+
+	_, err = wpgx.LoadMigrations(fstest.MapFS{"3_items.down.sql": {Data: []byte(`SELECT 1;`)}}, ".")
+	assert.EqualError(t, err, "no up migration of version 3")
+}
+
+func TestMigrator(t *testing.T) {
+	rec := new(recorder)
+
+	db, err := wpgx.Connect(connStr, wpgx.PoolSize(2), wpgx.Observe(rec))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE IF EXISTS migrate_items, wpgx_migrations;`)
+		assert.NoError(t, err)
+	}()
+
+	ctx := context.Background()
+	files := migrationFiles()
+
+	m, err := wpgx.NewMigrator(db, files)
+	assert.NoError(t, err)
+
+	n, err := m.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// Transactional migration runs in a dealer, so it is observed
+	assert.Contains(t, rec.kinds(), wpgx.EventCommit)
+
+	n, err = m.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	assert.NoError(t, m.Redo(ctx))
+
+	list, err := m.Status(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.True(t, list[0].Applied)
+		assert.True(t, list[1].Applied)
+	}
+
+	n, err = m.Down(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Applied migration is edited, so nothing runs
+	files["0001_create_items.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE migrate_items (id int);`)}
+
+	m, err = wpgx.NewMigrator(db, files)
+	assert.NoError(t, err)
+
+	_, err = m.Up(ctx)
+	assert.Equal(t, wpgx.ErrMigrationChanged, errors.Cause(err))
+
+	list, err = m.Status(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.True(t, list[0].Changed)
+		assert.False(t, list[1].Applied)
+	}
+
+	delete(files, "0002_index_name.up.sql")
+	delete(files, "0002_index_name.down.sql")
+	files["0001_create_items.up.sql"] = migrationFiles()["0001_create_items.up.sql"]
+
+	m, err = wpgx.NewMigrator(db, files)
+	assert.NoError(t, err)
+
+	// Migrations run on the locked connection, so the last free one is enough
+	busy, err := db.NewDealer()
+	assert.NoError(t, err)
+
+	tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	n, err = m.Down(tctx, 10)
+	cancel()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, busy.Jail(false))
+
+	_, err = wpgx.NewMigrator(nil, files)
+	assert.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package wpgx
+
+import (
+	"context"
+	"time"
+)
+
+// EventKind is a kind of the observed event
+type EventKind int
+
+// Kinds of the observed events
+const (
+	EventAcquire EventKind = iota
+	EventBegin
+	EventCommit
+	EventRollback
+	EventQueryStart
+	EventQueryFinish
+)
+
+// RawKey is the key of the observed queries, that were not cooked
+const RawKey = "raw"
+
+var eventNames = [...]string{"acquire", "begin", "commit", "rollback", "query_start", "query_finish"}
+
+func (k EventKind) String() string {
+	if k < 0 || int(k) >= len(eventNames) {
+		return "unknown"
+	}
+	return eventNames[k]
+}
+
+// Event describes what happened with a dealer or a query
+//
+// Duration is a wait time of the pool for acquire, a transaction time for commit and
+// rollback, and a query time for the query finish
+//
+// Key is a statement key of the query. Queries, that were not cooked, have the RawKey,
+// so the number of keys is limited by the registry
+//
+// Rows is a number of scanned or affected rows. Class is an error class, like SQLSTATE
+// class "23" or "connection", "canceled" and "other" for errors outside the database
+type Event struct {
+	Kind     EventKind
+	Key      string
+	Query    string
+	Duration time.Duration
+	Rows     int
+	Class    string
+	Err      error
+}
+
+// Observer receives events of the dealers and queries. It is called synchronously,
+// so it should be fast and safe for concurrent use
+type Observer interface {
+	Observe(e Event)
+}
+
+func (c *conn) observe(e Event) {
+	if c.observer != nil {
+		c.observer.Observe(e)
+	}
+}
+
+// watch sends the start event of the query and returns a function for the finish one
+// The finish is sent just once. When the context is done, fail sends it before the rollback
+func (t *tx) watch(query string) func(rows int, err error) {
+	c := t.c
+
+	if c.observer == nil {
+		return func(int, error) {}
+	}
+
+	e := Event{Kind: EventQueryStart, Query: query}
+
+	if st, ok := c.stmts.get(query); ok {
+		e.Key, e.Query = st.key, st.text
+	} else {
+		e.Key = RawKey
+	}
+
+	c.observer.Observe(e)
+	start := time.Now()
+
+	var sent bool
+
+	t.watching = func(rows int, err error) {
+		if sent {
+			return
+		}
+
+		sent, t.watching = true, nil
+
+		e.Kind = EventQueryFinish
+		e.Duration = time.Since(start)
+		e.Rows = rows
+		e.Class = errClass(err)
+		e.Err = err
+		c.observer.Observe(e)
+	}
+
+	return t.watching
+}
+
+// done sends the end event of the root dealer just once
+func (t *tx) done(commit bool, err error) {
+	if t.parent != nil || t.c == nil || t.start.IsZero() {
+		return
+	}
+
+	e := Event{Kind: EventRollback, Duration: time.Since(t.start), Class: errClass(err), Err: err}
+	if commit {
+		e.Kind = EventCommit
+	}
+
+	t.start = time.Time{}
+	t.c.observe(e)
+	t.finish(commit, err)
+}
+
+// errClass is a short error class for metrics
+func errClass(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if code := errCode(err); len(code) >= 2 {
+		return code[:2]
+	}
+
+	if IsConnectionLost(err) {
+		return "connection"
+	}
+
+	for cause := err; cause != nil; {
+		if cause == context.Canceled || cause == context.DeadlineExceeded {
+			return "canceled"
+		}
+
+		next, ok := cause.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		cause = next.Cause()
+	}
+
+	return "other"
+}
@@ -0,0 +1,248 @@
+package wpgx_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	sync.Mutex
+	events []wpgx.Event
+}
+
+func (r *recorder) Observe(e wpgx.Event) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) kinds() []wpgx.EventKind {
+	r.Lock()
+	defer r.Unlock()
+
+	kinds := make([]wpgx.EventKind, len(r.events))
+	for i := range r.events {
+		kinds[i] = r.events[i].Kind
+	}
+	return kinds
+}
+
+func (r *recorder) last() wpgx.Event {
+	r.Lock()
+	defer r.Unlock()
+	return r.events[len(r.events)-1]
+}
+
+func (r *recorder) reset() {
+	r.Lock()
+	defer r.Unlock()
+	r.events = nil
+}
+
+func TestObserver(t *testing.T) {
+	rec := new(recorder)
+
+	db, err := wpgx.Connect(connStr, wpgx.Observe(rec))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlSelect, err := db.Cook(`SELECT generate_series(1, $1) AS val;`)
+	assert.NoError(t, err)
+
+	rec.reset()
+
+	var ints wpgx.Ints
+	assert.NoError(t, db.Deal(&ints, sqlSelect, 3))
+	assert.Equal(t, []wpgx.EventKind{
+		wpgx.EventAcquire,
+		wpgx.EventBegin,
+		wpgx.EventQueryStart,
+		wpgx.EventQueryFinish,
+		wpgx.EventCommit,
+	}, rec.kinds())
+
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+	assert.NoError(t, d.Deal(&ints, sqlSelect, 3))
+
+	e := rec.last()
+	assert.Equal(t, wpgx.EventQueryFinish, e.Kind)
+	assert.Equal(t, sqlSelect, e.Key)
+	assert.Equal(t, `SELECT generate_series(1, $1) AS val;`, e.Query)
+	assert.Equal(t, 3, e.Rows)
+	assert.Equal(t, "", e.Class)
+	assert.True(t, e.Duration > 0)
+
+	assert.Error(t, d.Deal(nil, `SELECT 1 / $1::int;`, 0))
+
+	e = rec.last()
+	assert.Equal(t, wpgx.EventQueryFinish, e.Kind)
+	assert.Equal(t, "22", e.Class)
+	assert.Error(t, e.Err)
+	assert.Equal(t, wpgx.RawKey, e.Key)
+
+	assert.NoError(t, d.Jail(false))
+	assert.Equal(t, wpgx.EventRollback, rec.last().Kind)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d, err = db.NewDealerContext(ctx)
+	assert.NoError(t, err)
+
+	cancel()
+	assert.Error(t, d.Deal(nil, `SELECT pg_sleep(1);`))
+
+	kinds := rec.kinds()
+	assert.Equal(t, []wpgx.EventKind{
+		wpgx.EventQueryStart,
+		wpgx.EventQueryFinish,
+		wpgx.EventRollback,
+	}, kinds[len(kinds)-3:])
+
+	e = rec.last()
+	assert.Equal(t, wpgx.EventRollback, e.Kind)
+	assert.Equal(t, "canceled", e.Class)
+}
+
+func TestMetrics(t *testing.T) {
+	m := wpgx.NewMetrics(0.1, 1)
+
+	m.Observe(wpgx.Event{Kind: wpgx.EventAcquire, Duration: 50 * time.Millisecond})
+	m.Observe(wpgx.Event{Kind: wpgx.EventBegin})
+	m.Observe(wpgx.Event{Kind: wpgx.EventQueryStart, Key: "k1"})
+	m.Observe(wpgx.Event{Kind: wpgx.EventQueryFinish, Key: "k1", Duration: 500 * time.Millisecond, Rows: 7})
+	m.Observe(wpgx.Event{Kind: wpgx.EventQueryStart, Key: "k1"})
+	m.Observe(wpgx.Event{Kind: wpgx.EventQueryFinish, Key: "k1", Duration: 2 * time.Second, Class: "23"})
+	m.Observe(wpgx.Event{Kind: wpgx.EventQueryStart, Key: "k2"})
+	m.Observe(wpgx.Event{Kind: wpgx.EventRollback})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, err := ioutil.ReadAll(w.Result().Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP wpgx_transactions_total Number of begun, committed and rolled back dealers.
+# TYPE wpgx_transactions_total counter
+wpgx_transactions_total{event="begin"} 1
+wpgx_transactions_total{event="commit"} 0
+wpgx_transactions_total{event="rollback"} 1
+# HELP wpgx_pool_acquire_seconds Wait time for a pool connection.
+# TYPE wpgx_pool_acquire_seconds histogram
+wpgx_pool_acquire_seconds_bucket{le="0.1"} 1
+wpgx_pool_acquire_seconds_bucket{le="1"} 1
+wpgx_pool_acquire_seconds_bucket{le="+Inf"} 1
+wpgx_pool_acquire_seconds_sum 0.05
+wpgx_pool_acquire_seconds_count 1
+# HELP wpgx_queries_in_flight Number of running queries.
+# TYPE wpgx_queries_in_flight gauge
+wpgx_queries_in_flight 1
+# HELP wpgx_query_duration_seconds Query time by statement key.
+# TYPE wpgx_query_duration_seconds histogram
+wpgx_query_duration_seconds_bucket{key="k1",le="0.1"} 0
+wpgx_query_duration_seconds_bucket{key="k1",le="1"} 1
+wpgx_query_duration_seconds_bucket{key="k1",le="+Inf"} 2
+wpgx_query_duration_seconds_sum{key="k1"} 2.5
+wpgx_query_duration_seconds_count{key="k1"} 2
+# HELP wpgx_query_rows_total Number of scanned or affected rows by statement key.
+# TYPE wpgx_query_rows_total counter
+wpgx_query_rows_total{key="k1"} 7
+# HELP wpgx_query_errors_total Number of failed queries by statement key and error class.
+# TYPE wpgx_query_errors_total counter
+wpgx_query_errors_total{key="k1",class="23"} 1
+`, string(body))
+}
+
+func TestTracer(t *testing.T) {
+	rec := new(wpgx.Recorder)
+
+	db, err := wpgx.Connect(connStr, wpgx.Trace(rec))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlSelect, err := db.Cook(`SELECT generate_series(1, $1) AS val WHERE 'secret' <> '';`)
+	assert.NoError(t, err)
+
+	rec.Reset()
+
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+
+	var ints wpgx.Ints
+	assert.NoError(t, d.Deal(&ints, sqlSelect, 3))
+
+	var row struct {
+		Val int `db:"val"`
+	}
+	assert.NoError(t, d.Load(wpgx.Shape(&row), `SELECT 42 AS val;`))
+	assert.Error(t, d.Deal(nil, `SELECT 1 / $1::int;`, 0))
+	assert.NoError(t, d.Jail(false))
+
+	spans := rec.Spans()
+	assert.Len(t, spans, 4)
+
+	assert.Equal(t, "wpgx.transaction", spans[0].Name)
+	assert.Equal(t, -1, spans[0].Parent)
+	assert.Equal(t, false, spans[0].Attributes["wpgx.commit"])
+	assert.True(t, spans[0].Ended)
+
+	assert.Equal(t, "wpgx.deal", spans[1].Name)
+	assert.Equal(t, 0, spans[1].Parent)
+	assert.Equal(t, sqlSelect, spans[1].Attributes["wpgx.key"])
+	assert.Equal(t, `SELECT generate_series(?, $1) AS val WHERE ? <> ?;`, spans[1].Attributes["db.statement"])
+	assert.Equal(t, 3, spans[1].Attributes["db.rows"])
+	assert.NoError(t, spans[1].Err)
+
+	assert.Equal(t, "wpgx.load", spans[2].Name)
+	assert.Equal(t, 0, spans[2].Parent)
+	assert.Equal(t, 1, spans[2].Attributes["db.rows"])
+
+	assert.Equal(t, "wpgx.deal", spans[3].Name)
+	assert.Error(t, spans[3].Err)
+	assert.True(t, spans[3].Ended)
+}
+
+type records chan []wpgx.Field
+
+func (r records) Log(level pgx.LogLevel, msg string, fields []wpgx.Field) {
+	if msg == "slow query" {
+		r <- fields
+	}
+}
+
+func TestSlowQuery(t *testing.T) {
+	logs := make(records, 2)
+
+	db, err := wpgx.Connect(connStr, wpgx.Logging(logs), wpgx.SlowQuery(50*time.Millisecond, true))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlSleep, err := db.Cook(`SELECT pg_sleep($1) IS NULL AS val;`)
+	assert.NoError(t, err)
+
+	var ints wpgx.Ints
+	assert.NoError(t, db.Deal(&ints, `SELECT 1 AS val;`))
+	assert.NoError(t, db.Deal(&ints, sqlSleep, 0.1))
+
+	select {
+	case fields := <-logs:
+		assert.Len(t, fields, 5)
+		assert.Equal(t, wpgx.Field{Key: "args", Value: []string{"float"}}, fields[0])
+		assert.True(t, fields[1].Value.(time.Duration) >= 50*time.Millisecond)
+		assert.Equal(t, wpgx.Field{Key: "key", Value: sqlSleep}, fields[2])
+		assert.Equal(t, "plan", fields[3].Key)
+		assert.Contains(t, fields[3].Value, `"Node Type": "Result"`)
+		assert.Equal(t, wpgx.Field{Key: "sql", Value: `SELECT pg_sleep($1) IS NULL AS val;`}, fields[4])
+	case <-time.After(5 * time.Second):
+		t.Fatal("no slow query record")
+	}
+
+	assert.Empty(t, logs)
+}
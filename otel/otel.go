@@ -0,0 +1,61 @@
+// Package wpgxotel is an OpenTelemetry adapter of the wpgx tracer
+// It is a separate module, so wpgx itself does not depend on OpenTelemetry
+//
+// Usage:
+//
+//	tracer := wpgxotel.New(otel.Tracer("wpgx"))
+//	db, err := wpgx.Connect(connStr, wpgx.Trace(tracer))
+package wpgxotel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shestakovda/wpgx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer starts client spans of the OpenTelemetry tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New makes wpgx tracer of the OpenTelemetry one
+func New(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, wpgx.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, &otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case float64:
+		s.span.SetAttributes(attribute.Float64(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s *otelSpan) SetError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *otelSpan) End() { s.span.End() }
@@ -0,0 +1,333 @@
+package wpgx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
+	"github.com/pkg/errors"
+)
+
+// ErrBadToken occurs when the page token is broken or made by another pager
+var ErrBadToken = errors.New("invalid page token")
+
+// Page describes the loaded page. Tokens are opaque and made of the key columns
+// of the first and the last rows. They are empty when the page has no rows
+type Page struct {
+	Next    string
+	Prev    string
+	HasNext bool
+	HasPrev bool
+}
+
+// Pager reads pages of the query ordered by the key columns without OFFSET
+// Keys must be unique together and sorted ascending, like "created_at", "id"
+//
+// Next loads the page after the token row. Empty token means the first page
+//
+// Prev loads the page before the token row. Empty token means the last page
+//
+// Query arguments are passed after the token each time
+type Pager struct {
+	c     *conn
+	size  int
+	keys  []string
+	texts [4]string
+	names [4]string
+}
+
+// Wrapped queries of the pager, going forward and backward with or without a token
+const (
+	pageAfter = iota
+	pageFirst
+	pageUntil
+	pageLast
+)
+
+func (c *conn) Pager(query string, size int, keys ...string) (*Pager, error) {
+	return c.PagerContext(context.Background(), query, size, keys...)
+}
+
+func (c *conn) PagerContext(ctx context.Context, query string, size int, keys ...string) (p *Pager, err error) {
+	var d Dealer
+	const emsg = "building pager"
+
+	if size <= 0 {
+		return nil, errors.New("invalid page size")
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no key columns")
+	}
+
+	if err = c.ready(); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	if st, ok := c.stmts.get(query); ok {
+		query = st.text
+	} else if err = unknown(query); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	// Named query is rewritten, so the key parameters go after the positional ones
+	if query, _, err = params(query); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	var count int
+
+	if _, _, count, err = parseParams(query); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+
+	if d, err = c.NewDealerContext(ctx); err != nil {
+		return nil, errors.Wrap(err, emsg)
+	}
+	defer func() { d.Jail(err == nil) }()
+
+	p = &Pager{c: c, size: size, keys: keys}
+	p.texts[pageAfter] = p.build(query, count, ">", "ASC")
+	p.texts[pageFirst] = p.build(query, count, "", "ASC")
+	p.texts[pageUntil] = p.build(query, count, "<", "DESC")
+	p.texts[pageLast] = p.build(query, count, "", "DESC")
+
+	for i := range p.texts {
+		if p.names[i], err = d.CookContext(ctx, p.texts[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// build wraps the query, so that the key columns and number of rows are selected
+// Keys are selected in the text form, so the server parses them back into any type
+// One extra row is selected to know, whether there are more pages
+func (p *Pager) build(query string, count int, op, order string) string {
+	sel := make([]string, len(p.keys))
+	cols := make([]string, len(p.keys))
+	sort := make([]string, len(p.keys))
+	outer := make([]string, len(p.keys))
+
+	for i := range p.keys {
+		name := pgx.Identifier{p.keys[i]}.Sanitize()
+		cols[i] = "wpgx_q." + name
+		sel[i] = cols[i] + "::text AS wpgx_key_" + strconv.Itoa(i)
+		sort[i] = cols[i] + " " + order
+		outer[i] = "wpgx_p." + name
+	}
+
+	text := "SELECT wpgx_q.*, " + strings.Join(sel, ", ") + " FROM (" + query + ") AS wpgx_q"
+
+	if op != "" {
+		text += " WHERE (" + strings.Join(cols, ", ") + ") " + op + " (" + places(count+1, len(p.keys)) + ")"
+	}
+
+	text += " ORDER BY " + strings.Join(sort, ", ") + " LIMIT " + strconv.Itoa(p.size+1)
+
+	return "SELECT wpgx_p.*, count(*) OVER () AS wpgx_rows FROM (" + text + ") AS wpgx_p" +
+		" ORDER BY " + strings.Join(outer, ", ") + ";"
+}
+
+func (p *Pager) Next(d Dealer, result Collector, token string, args ...interface{}) (Page, error) {
+	return p.NextContext(context.Background(), d, result, token, args...)
+}
+
+func (p *Pager) Prev(d Dealer, result Collector, token string, args ...interface{}) (Page, error) {
+	return p.PrevContext(context.Background(), d, result, token, args...)
+}
+
+func (p *Pager) NextContext(ctx context.Context, d Dealer, result Collector, token string, args ...interface{}) (Page, error) {
+	if token == "" {
+		return p.load(ctx, d, result, false, false, pageFirst, args)
+	}
+	return p.load(ctx, d, result, false, true, pageAfter, args, token)
+}
+
+func (p *Pager) PrevContext(ctx context.Context, d Dealer, result Collector, token string, args ...interface{}) (Page, error) {
+	if token == "" {
+		return p.load(ctx, d, result, true, false, pageLast, args)
+	}
+	return p.load(ctx, d, result, true, true, pageUntil, args, token)
+}
+
+func (p *Pager) load(ctx context.Context, d Dealer, result Collector, back, from bool, kind int, args []interface{}, token ...string) (page Page, err error) {
+	const emsg = "loading page"
+
+	// It is cooked again, when evicted from the registry
+	query := p.names[kind]
+	if _, ok := p.c.stmts.get(query); !ok {
+		if query, err = d.CookContext(ctx, p.texts[kind]); err != nil {
+			return page, err
+		}
+	}
+
+	if from {
+		var keys []interface{}
+
+		if keys, err = p.decode(token[0]); err != nil {
+			return page, errors.Wrap(err, emsg)
+		}
+
+		args = append(args[:len(args):len(args)], keys...)
+	}
+
+	list := &pageList{Collector: result, size: p.size, keys: len(p.keys), back: back}
+
+	if err = d.DealContext(ctx, list, query, args...); err != nil {
+		return page, err
+	}
+
+	more := list.rows > int64(p.size)
+
+	if back {
+		page.HasPrev, page.HasNext = more, from
+	} else {
+		page.HasNext, page.HasPrev = more, from
+	}
+
+	if list.first != nil {
+		if page.Prev, err = p.encode(list.first); err != nil {
+			return page, errors.Wrap(err, emsg)
+		}
+		if page.Next, err = p.encode(list.last); err != nil {
+			return page, errors.Wrap(err, emsg)
+		}
+	}
+
+	return page, nil
+}
+
+// encode makes a token of the key values in the text form
+func (p *Pager) encode(keys []pgtype.Text) (string, error) {
+	var err error
+
+	dump := make([]reserveArg, len(keys))
+
+	for i := range keys {
+		var value interface{}
+
+		if keys[i].Status == pgtype.Present {
+			value = keys[i].String
+		}
+
+		if dump[i], err = dumpArg(p.keys[i], value); err != nil {
+			return "", err
+		}
+	}
+
+	text, err := json.Marshal(dump)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(text), nil
+}
+
+func (p *Pager) decode(token string) ([]interface{}, error) {
+	var dump []reserveArg
+
+	text, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(text, &dump) != nil || len(dump) != len(p.keys) {
+		return nil, ErrBadToken
+	}
+
+	keys := make([]interface{}, len(dump))
+
+	for i := range dump {
+		if dump[i].Name != p.keys[i] {
+			return nil, ErrBadToken
+		}
+		if keys[i], err = dump[i].restore(); err != nil {
+			return nil, ErrBadToken
+		}
+	}
+
+	return keys, nil
+}
+
+// pageList collects the page rows and remembers keys of the first and the last ones
+// Extra row is the last one going forward and the first one going backward
+type pageList struct {
+	Collector
+	size int
+	keys int
+	back bool
+	seen int
+	rows int64
+
+	first []pgtype.Text
+	last  []pgtype.Text
+}
+
+func (l *pageList) NewItem() Shaper {
+	if !l.back && l.seen >= l.size {
+		return nil
+	}
+
+	item := l.Collector.NewItem()
+	if item == nil {
+		return nil
+	}
+
+	return &pageItem{Shaper: item, keys: make([]pgtype.Text, l.keys)}
+}
+
+func (l *pageList) Collect(item Shaper) error {
+	row, ok := item.(*pageItem)
+	if !ok {
+		return ErrUnknownType
+	}
+
+	l.seen++
+	l.rows = row.rows
+
+	if l.back && l.seen == 1 && l.rows > int64(l.size) {
+		return nil
+	}
+
+	if l.first == nil {
+		l.first = row.keys
+	}
+	l.last = row.keys
+
+	return l.Collector.Collect(row.Shaper)
+}
+
+// pageItem catches the key columns and passes the others to the item model
+type pageItem struct {
+	Shaper
+	model Translator
+	keys  []pgtype.Text
+	rows  int64
+}
+
+func (i *pageItem) Extrude() Translator {
+	i.model = i.Shaper.Extrude()
+	return i
+}
+
+func (i *pageItem) Receive(model Translator) error {
+	return i.Shaper.Receive(i.model)
+}
+
+func (i *pageItem) Translate(name string) interface{} {
+	if name == "wpgx_rows" {
+		return &i.rows
+	}
+
+	if strings.HasPrefix(name, "wpgx_key_") {
+		if n, err := strconv.Atoi(name[len("wpgx_key_"):]); err == nil && n < len(i.keys) {
+			return &i.keys[n]
+		}
+	}
+
+	return i.model.Translate(name)
+}
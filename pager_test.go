@@ -0,0 +1,142 @@
+package wpgx_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+type pageUser struct {
+	ID    int    `db:"id"`
+	Group string `db:"grp"`
+}
+
+func pageIDs(list []pageUser) []int {
+	ids := make([]int, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	return ids
+}
+
+func TestPager(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE page_users AS SELECT id, CASE WHEN id < 6 THEN 'a' ELSE 'b' END AS grp FROM generate_series(1, 7) AS id;`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE page_users;`)
+		assert.NoError(t, err)
+	}()
+
+	_, err = db.Pager(`SELECT * FROM page_users;`, 0, "id")
+	assert.EqualError(t, err, "invalid page size")
+
+	_, err = db.Pager(`SELECT * FROM page_users;`, 2)
+	assert.EqualError(t, err, "no key columns")
+
+	_, err = db.Pager(`SELECT * FROM page_users;`, 2, "nothing")
+	assert.Error(t, err)
+
+	pager, err := db.Pager(`SELECT * FROM page_users WHERE grp = :grp;`, 2, "id")
+	assert.NoError(t, err)
+
+	var list []pageUser
+
+	page, err := pager.Next(db, wpgx.Slice(&list), "", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, pageIDs(list))
+	assert.True(t, page.HasNext)
+	assert.False(t, page.HasPrev)
+
+	list = list[:0]
+	page, err = pager.Next(db, wpgx.Slice(&list), page.Next, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 4}, pageIDs(list))
+	assert.True(t, page.HasNext)
+	assert.True(t, page.HasPrev)
+
+	list = list[:0]
+	last, err := pager.Next(db, wpgx.Slice(&list), page.Next, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5}, pageIDs(list))
+	assert.False(t, last.HasNext)
+	assert.True(t, last.HasPrev)
+
+	list = list[:0]
+	page, err = pager.Prev(db, wpgx.Slice(&list), page.Prev, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, pageIDs(list))
+	assert.False(t, page.HasPrev)
+	assert.True(t, page.HasNext)
+
+	list = list[:0]
+	page, err = pager.Prev(db, wpgx.Slice(&list), "", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{4, 5}, pageIDs(list))
+	assert.True(t, page.HasPrev)
+	assert.False(t, page.HasNext)
+
+	list = list[:0]
+	page, err = pager.Next(db, wpgx.Slice(&list), last.Next, "a")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+	assert.Empty(t, page.Next)
+	assert.False(t, page.HasNext)
+
+	_, err = pager.Next(db, wpgx.Slice(&list), "broken", "a")
+	assert.Equal(t, wpgx.ErrBadToken, errors.Cause(err))
+
+	// Tokens of composite keys keep their types
+	pager, err = db.Pager(`SELECT * FROM page_users`, 3, "grp", "id")
+	assert.NoError(t, err)
+
+	d, err := db.NewDealer()
+	assert.NoError(t, err)
+	defer d.Jail(false)
+
+	list = list[:0]
+	page, err = pager.Prev(d, wpgx.Slice(&list), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5, 6, 7}, pageIDs(list))
+
+	list = list[:0]
+	page, err = pager.Prev(d, wpgx.Slice(&list), page.Prev)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 4}, pageIDs(list))
+	assert.True(t, page.HasPrev)
+
+	// Keys of any type are compared by the server in their own order
+	err = db.Deal(nil, `CREATE TABLE page_items AS SELECT id, md5(id::text)::uuid AS uid, id::numeric / 4 AS num FROM generate_series(1, 12) AS id;`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE page_items;`)
+		assert.NoError(t, err)
+	}()
+
+	for _, key := range []string{"num", "uid"} {
+		pager, err = db.Pager(`SELECT * FROM page_items`, 5, key)
+		assert.NoError(t, err)
+
+		var seen []int
+		page = wpgx.Page{}
+
+		for i := 0; i < 3; i++ {
+			list = list[:0]
+			page, err = pager.Next(d, wpgx.Slice(&list), page.Next)
+			assert.NoError(t, err)
+			seen = append(seen, pageIDs(list)...)
+		}
+
+		assert.False(t, page.HasNext)
+		assert.Len(t, seen, 12)
+
+		if key == "num" {
+			assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, seen)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package wpgx
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// params rewrites :name and @name placeholders of the query into positional ones
+// Columns of the named query are taken from the placeholders in the order of positions
+// Columns of the positional query must match the parameters count
+func params(text string, cols ...string) (string, []string, error) {
+	query, names, count, err := parseParams(text)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(names) == 0 {
+		if len(cols) > 0 && len(cols) != count {
+			return "", nil, errors.Errorf("%d columns for %d parameters", len(cols), count)
+		}
+		return text, cols, nil
+	}
+
+	if count > 0 {
+		return "", nil, errors.New("mixed named and positional parameters")
+	}
+
+	if len(cols) > 0 && strings.Join(cols, ",") != strings.Join(names, ",") {
+		return "", nil, errors.New("columns do not match named parameters: " + strings.Join(names, ", "))
+	}
+
+	return query, names, nil
+}
+
+// parseParams finds placeholders outside of literals, quoted identifiers and comments
+// It returns rewritten query, unique names and the highest positional parameter
+func parseParams(text string) (string, []string, int, error) {
+	var count, depth int
+	var names []string
+
+	pos := make(map[string]int)
+	buf := make([]byte, 0, len(text))
+
+	for i := 0; i < len(text); {
+		ch := text[i]
+		next := byte(0)
+		if i+1 < len(text) {
+			next = text[i+1]
+		}
+
+		switch {
+		case ch == '\'' || ch == '"':
+			end := skipQuoted(text, i, ch)
+			buf = append(buf, text[i:end]...)
+			i = end
+			continue
+		case ch == '-' && next == '-':
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			buf = append(buf, text[i:i+end]...)
+			i += end
+			continue
+		case ch == '/' && next == '*':
+			end := skipComment(text, i)
+			buf = append(buf, text[i:end]...)
+			i = end
+			continue
+		case ch == '$' && isDigit(next):
+			end := i + 1
+			for end < len(text) && isDigit(text[end]) {
+				end++
+			}
+			if n, err := strconv.Atoi(text[i+1 : end]); err == nil && n > count {
+				count = n
+			}
+			buf = append(buf, text[i:end]...)
+			i = end
+			continue
+		case ch == '$':
+			if end, ok := skipDollar(text, i); ok {
+				buf = append(buf, text[i:end]...)
+				i = end
+				continue
+			}
+		case ch == '[':
+			depth++
+		case ch == ']' && depth > 0:
+			depth--
+		case ch == ':' && (next == ':' || next == '='):
+			buf = append(buf, ch, next)
+			i += 2
+			continue
+		case ch == ':' && depth > 0:
+			// Array slice, like arr[lo:hi]
+		case ch == ':' || ch == '@':
+			end := i + 1
+			for end < len(text) && isIdent(text[end], end > i+1) {
+				end++
+			}
+
+			if end == i+1 {
+				if ch == '@' {
+					// Operator, like @> or abs
+					break
+				}
+				return "", nil, 0, errors.New("loose placeholder at position " + strconv.Itoa(i))
+			}
+
+			name := text[i+1 : end]
+			n, ok := pos[name]
+			if !ok {
+				names = append(names, name)
+				n = len(names)
+				pos[name] = n
+			}
+
+			buf = append(buf, '$')
+			buf = strconv.AppendInt(buf, int64(n), 10)
+			i = end
+			continue
+		}
+
+		buf = append(buf, ch)
+		i++
+	}
+
+	return string(buf), names, count, nil
+}
+
+// skipQuoted returns the end of the literal or identifier, doubled quote is escaped
+func skipQuoted(text string, i int, quote byte) int {
+	escapes := quote == '\'' && i > 0 && (text[i-1] == 'E' || text[i-1] == 'e')
+
+	for i++; i < len(text); i++ {
+		switch {
+		case escapes && text[i] == '\\':
+			i++
+		case text[i] == quote && i+1 < len(text) && text[i+1] == quote:
+			i++
+		case text[i] == quote:
+			return i + 1
+		}
+	}
+	return len(text)
+}
+
+// skipComment returns the end of the block comment, they can be nested
+func skipComment(text string, i int) int {
+	depth := 0
+
+	for ; i+1 < len(text); i++ {
+		switch text[i : i+2] {
+		case "/*":
+			depth++
+			i++
+		case "*/":
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(text)
+}
+
+// skipDollar returns the end of the dollar quoted string, like $tag$text$tag$
+func skipDollar(text string, i int) (int, bool) {
+	end := i + 1
+	for end < len(text) && isIdent(text[end], end > i+1) {
+		end++
+	}
+
+	if end >= len(text) || text[end] != '$' {
+		return i, false
+	}
+
+	tag := text[i : end+1]
+
+	if n := strings.Index(text[end+1:], tag); n >= 0 {
+		return end + 1 + n + len(tag), true
+	}
+	return len(text), true
+}
+
+func isDigit(ch byte) bool { return '0' <= ch && ch <= '9' }
+
+func isIdent(ch byte, tail bool) bool {
+	return ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || (tail && isDigit(ch))
+}
@@ -0,0 +1,88 @@
+package wpgx_test
+
+import (
+	"testing"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParams(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE param_users (id serial PRIMARY KEY, name text not null, role_id int);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE param_users;`)
+		assert.NoError(t, err)
+	}()
+
+	type paramUser struct {
+		ID     int    `db:"id"`
+		Name   string `db:"name"`
+		RoleID int    `db:"role_id"`
+	}
+
+	// Columns are taken from the placeholders
+	sqlInsert, err := db.Cook(`INSERT INTO param_users (name, role_id) VALUES (:name, @role_id) RETURNING id;`)
+	assert.NoError(t, err)
+
+	var ids []paramUser
+	err = db.Save(wpgx.Shape(&paramUser{Name: "first", RoleID: 2}), sqlInsert, wpgx.Slice(&ids))
+	assert.NoError(t, err)
+	assert.Equal(t, []paramUser{{ID: 1}}, ids)
+
+	// Repeated name is the same parameter
+	sqlUpdate, err := db.Cook(`UPDATE param_users SET name = :name WHERE id = :id AND name <> :name;`, "name", "id")
+	assert.NoError(t, err)
+
+	err = db.Save(wpgx.Shape(&paramUser{ID: 1, Name: "second"}), sqlUpdate, nil)
+	assert.NoError(t, err)
+
+	var users []paramUser
+	err = db.Deal(wpgx.Slice(&users), `SELECT * FROM param_users;`)
+	assert.NoError(t, err)
+	assert.Equal(t, []paramUser{{ID: 1, Name: "second", RoleID: 2}}, users)
+
+	// Placeholders in literals, identifiers, comments, slices and named arguments are left as is
+	sqlSelect, err := db.Cook(`SELECT ':a' AS a, "@b" AS b, E'\':c' AS c, $$ :d $$ AS d, $x$ @e $x$ AS e,
+		(ARRAY[1, 2, 3])[2:3] AS f, (ARRAY[1, 2, 3])[lo:hi] AS g, '{"h": 1}'::jsonb @> '{}' AS h,
+		make_interval(days := 1)::text AS i /* :f /* @g */ */ -- :h
+	FROM (SELECT 1 AS lo, 2 AS hi, 'b' AS "@b") AS t WHERE lo = :id;`)
+	assert.NoError(t, err)
+
+	type row struct {
+		A string  `db:"a"`
+		B string  `db:"b"`
+		C string  `db:"c"`
+		D string  `db:"d"`
+		E string  `db:"e"`
+		F []int32 `db:"f"`
+		G []int32 `db:"g"`
+		H bool    `db:"h"`
+		I string  `db:"i"`
+	}
+
+	var rows []row
+	err = db.Save(wpgx.Shape(&paramUser{ID: 1}), sqlSelect, wpgx.Slice(&rows))
+	assert.NoError(t, err)
+	assert.Equal(t, []row{{":a", "b", "':c", " :d ", " @e ", []int32{2, 3}, []int32{1, 2}, true, "1 day"}}, rows)
+
+	// Positional parameters are checked with columns
+	_, err = db.Cook(`SELECT $1::int, $2::int;`, "a", "b")
+	assert.NoError(t, err)
+
+	_, err = db.Cook(`SELECT $1::int, $2::int;`, "a")
+	assert.EqualError(t, err, "preparing statement: 1 columns for 2 parameters")
+
+	_, err = db.Cook(`SELECT $1::int, :a::int;`)
+	assert.EqualError(t, err, "preparing statement: mixed named and positional parameters")
+
+	_, err = db.Cook(`SELECT :a::int, :b::int;`, "b", "a")
+	assert.EqualError(t, err, "preparing statement: columns do not match named parameters: a, b")
+
+	_, err = db.Cook(`SELECT : a;`)
+	assert.EqualError(t, err, "preparing statement: loose placeholder at position 7")
+}
@@ -0,0 +1,173 @@
+package wpgx
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// QueryError is a database error with the statement context
+// Its message is the same as of the cause, which is a pgx.PgError or a network error
+//
+// Args has just types and sizes of the arguments, because they may be private
+type QueryError struct {
+	Code       string
+	Constraint string
+	Table      string
+	Column     string
+	Key        string
+	Query      string
+	Args       []string
+	Err        error
+}
+
+func (e *QueryError) Error() string { return e.Err.Error() }
+
+// Cause is for errors.Cause, so it returns the source error
+func (e *QueryError) Cause() error { return e.Err }
+
+// Unwrap is for errors.Is and errors.As of the standard library
+func (e *QueryError) Unwrap() error { return e.Err }
+
+// AsQueryError finds QueryError through all the wrappers
+func AsQueryError(err error) (*QueryError, bool) {
+	for err != nil {
+		if e, ok := err.(*QueryError); ok {
+			return e, true
+		}
+
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = cause.Cause()
+	}
+	return nil, false
+}
+
+// IsUniqueViolation checks for unique constraint violation
+func IsUniqueViolation(err error) bool { return errCode(err) == "23505" }
+
+// IsForeignKeyViolation checks for foreign key constraint violation
+func IsForeignKeyViolation(err error) bool { return errCode(err) == "23503" }
+
+// IsNotNullViolation checks for not null constraint violation
+func IsNotNullViolation(err error) bool { return errCode(err) == "23502" }
+
+// IsCheckViolation checks for check constraint violation
+func IsCheckViolation(err error) bool { return errCode(err) == "23514" }
+
+// IsSerializationFailure checks for serialization failure of the concurrent transactions
+func IsSerializationFailure(err error) bool { return errCode(err) == "40001" }
+
+// IsDeadlock checks for deadlock of the concurrent transactions
+func IsDeadlock(err error) bool { return errCode(err) == "40P01" }
+
+// IsConnectionLost checks for broken connection, server shutdown or network errors
+func IsConnectionLost(err error) bool {
+	if code := errCode(err); code != "" {
+		return strings.HasPrefix(code, "08") || code == "57P01" || code == "57P02" || code == "57P03"
+	}
+
+	for err != nil {
+		switch err.(type) {
+		case net.Error:
+			return true
+		}
+
+		if err == pgx.ErrDeadConn || err == io.EOF || err == io.ErrUnexpectedEOF {
+			return true
+		}
+
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = cause.Cause()
+	}
+	return false
+}
+
+// errCode finds SQLSTATE code of the error through all the wrappers
+func errCode(err error) string {
+	for err != nil {
+		switch e := err.(type) {
+		case *QueryError:
+			return e.Code
+		case pgx.PgError:
+			return e.Code
+		case *pgx.PgError:
+			return e.Code
+		}
+
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return ""
+		}
+		err = cause.Cause()
+	}
+	return ""
+}
+
+// query adds the statement context to the database error
+// Errors of the library itself and context errors are returned as is
+func (t *tx) query(err error, query string, args []interface{}) error {
+	if err == nil || (errCode(err) == "" && !IsConnectionLost(err)) {
+		return err
+	}
+
+	if _, ok := err.(*QueryError); ok {
+		return err
+	}
+
+	e := &QueryError{Query: query, Args: redact(args), Err: err}
+
+	switch pe := errors.Cause(err).(type) {
+	case pgx.PgError:
+		e.Code, e.Constraint, e.Table, e.Column = pe.Code, pe.ConstraintName, pe.TableName, pe.ColumnName
+	case *pgx.PgError:
+		e.Code, e.Constraint, e.Table, e.Column = pe.Code, pe.ConstraintName, pe.TableName, pe.ColumnName
+	}
+
+	if t.c != nil {
+		if st, ok := t.c.stmts.get(query); ok {
+			e.Key, e.Query = st.key, st.text
+		}
+	}
+
+	return e
+}
+
+// redact describes arguments without their values, like string(5) or null
+func redact(args []interface{}) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	list := make([]string, len(args))
+
+	for i := range args {
+		dump, err := dumpArg("", args[i])
+		if err != nil {
+			list[i] = "invalid"
+			continue
+		}
+
+		list[i] = dump.Type
+
+		switch dump.Type {
+		case "string", "bytes":
+			v := reflect.Indirect(reflect.ValueOf(args[i]))
+			if v.Kind() == reflect.String || v.Kind() == reflect.Slice {
+				list[i] += "(" + strconv.Itoa(v.Len()) + ")"
+			}
+		}
+	}
+
+	return list
+}
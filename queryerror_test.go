@@ -0,0 +1,79 @@
+package wpgx_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryError(t *testing.T) {
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE err_roles (id int PRIMARY KEY);`)
+	assert.NoError(t, err)
+	err = db.Deal(nil, `CREATE TABLE err_users (id int PRIMARY KEY, name text NOT NULL, role_id int REFERENCES err_roles);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE err_users, err_roles;`)
+		assert.NoError(t, err)
+	}()
+
+	sqlInsert, err := db.Cook(`INSERT INTO err_users (id, name, role_id) VALUES ($1, $2, $3);`)
+	assert.NoError(t, err)
+
+	err = db.Deal(nil, sqlInsert, 1, "secret", nil)
+	assert.NoError(t, err)
+
+	err = db.Deal(nil, sqlInsert, 1, "secret", nil)
+	assert.EqualError(t, err, "executing query: ERROR: duplicate key value violates unique constraint \"err_users_pkey\" (SQLSTATE 23505)")
+	assert.True(t, wpgx.IsUniqueViolation(err))
+	assert.False(t, wpgx.IsForeignKeyViolation(err))
+	assert.IsType(t, pgx.PgError{}, errors.Cause(err))
+
+	qe, ok := wpgx.AsQueryError(err)
+	assert.True(t, ok)
+	assert.Equal(t, "23505", qe.Code)
+	assert.Equal(t, "err_users_pkey", qe.Constraint)
+	assert.Equal(t, "err_users", qe.Table)
+	assert.Equal(t, sqlInsert, qe.Key)
+	assert.Equal(t, `INSERT INTO err_users (id, name, role_id) VALUES ($1, $2, $3);`, qe.Query)
+	assert.Equal(t, []string{"int", "string(6)", "null"}, qe.Args)
+	assert.NotContains(t, qe.Error(), "secret")
+
+	err = db.Deal(nil, sqlInsert, 2, "name", 1)
+	assert.True(t, wpgx.IsForeignKeyViolation(err))
+
+	err = db.Deal(nil, sqlInsert, 2, nil, nil)
+	assert.True(t, wpgx.IsNotNullViolation(err))
+
+	qe, ok = wpgx.AsQueryError(err)
+	assert.True(t, ok)
+	assert.Equal(t, "name", qe.Column)
+
+	var ints wpgx.Ints
+	err = db.Deal(&ints, `SELECT 1 / $1::int;`, 0)
+	assert.False(t, wpgx.IsUniqueViolation(err))
+
+	qe, ok = wpgx.AsQueryError(err)
+	assert.True(t, ok)
+	assert.Equal(t, "22012", qe.Code)
+	assert.Empty(t, qe.Key)
+	assert.Equal(t, `SELECT 1 / $1::int;`, qe.Query)
+
+	_, ok = wpgx.AsQueryError(errors.New("test"))
+	assert.False(t, ok)
+
+	assert.True(t, wpgx.IsSerializationFailure(errors.Wrap(pgx.PgError{Code: "40001"}, "test")))
+	assert.True(t, wpgx.IsDeadlock(&pgx.PgError{Code: "40P01"}))
+	assert.True(t, wpgx.IsConnectionLost(pgx.PgError{Code: "08006"}))
+	assert.True(t, wpgx.IsConnectionLost(errors.Wrap(io.EOF, "test")))
+	assert.True(t, wpgx.IsConnectionLost(pgx.ErrDeadConn))
+	assert.False(t, wpgx.IsConnectionLost(errors.New("test")))
+	assert.False(t, wpgx.IsConnectionLost(nil))
+}
@@ -0,0 +1,243 @@
+package wpgx
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// Delays of the read your writes wait, while replica replays the primary position
+const (
+	catchupMinDelay = 5 * time.Millisecond
+	catchupMaxDelay = 100 * time.Millisecond
+)
+
+// sqlReplicaLag is zero, when replica has replayed all received changes
+// Functions are named as of PostgreSQL 10, walQuery renames them for the older servers
+const sqlReplicaLag = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END::float8;`
+
+type readOnlyKey struct{}
+
+// ReadOnlyContext marks the context, so the connector methods run in read only dealers
+// They are balanced across replicas, but Save always goes to the primary
+func ReadOnlyContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+func isReadOnly(ctx context.Context) bool {
+	on, _ := ctx.Value(readOnlyKey{}).(bool)
+	return on
+}
+
+// replica is a pool of the standby server, it is out of rotation while unhealthy or lagging
+type replica struct {
+	pool *pgx.ConnPool
+	ok   int32
+}
+
+// replicas balances read only dealers across healthy replicas
+type replicas struct {
+	list   []*replica
+	next   uint64
+	maxLag time.Duration
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+func newReplicas(cfg *Config) (r *replicas, err error) {
+	r = &replicas{maxLag: cfg.MaxLag}
+
+	for _, uri := range cfg.Replicas {
+		pc := cfg.ConnPoolConfig
+
+		if pc.ConnConfig, err = pgx.ParseConnectionString(uri); err != nil {
+			r.close()
+			return nil, errors.Wrap(err, "parsing replica connection string")
+		}
+
+		pc.ConnConfig.Logger = cfg.ConnPoolConfig.ConnConfig.Logger
+		pc.ConnConfig.LogLevel = cfg.ConnPoolConfig.ConnConfig.LogLevel
+
+		rp := &replica{ok: 1}
+
+		if rp.pool, err = pgx.NewConnPool(pc); err != nil {
+			r.close()
+			return nil, errors.Wrap(err, "creating replica pool")
+		}
+
+		r.list = append(r.list, rp)
+	}
+
+	var ctx context.Context
+	ctx, r.cancel = context.WithCancel(context.Background())
+
+	for _, rp := range r.list {
+		r.done.Add(1)
+		go r.watch(ctx, rp, cfg.LagCheck)
+	}
+
+	return r, nil
+}
+
+// pick returns the next healthy replica pool or nil, when there is none
+func (r *replicas) pick() *pgx.ConnPool {
+	if r == nil || len(r.list) == 0 {
+		return nil
+	}
+
+	start := atomic.AddUint64(&r.next, 1)
+
+	for i := range r.list {
+		rp := r.list[(start+uint64(i))%uint64(len(r.list))]
+		if atomic.LoadInt32(&rp.ok) == 1 {
+			return rp.pool
+		}
+	}
+	return nil
+}
+
+// watch checks replay lag of the replica, until the connector is closed
+func (r *replicas) watch(ctx context.Context, rp *replica, interval time.Duration) {
+	defer r.done.Done()
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		lag, err := r.lag(ctx, rp.pool, interval)
+
+		if err == nil && lag <= r.maxLag {
+			atomic.StoreInt32(&rp.ok, 1)
+		} else {
+			atomic.StoreInt32(&rp.ok, 0)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (r *replicas) lag(ctx context.Context, pool *pgx.ConnPool, timeout time.Duration) (time.Duration, error) {
+	var sec float64
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cn, err := pool.AcquireEx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer pool.Release(cn)
+
+	if err = cn.QueryRowEx(ctx, walQuery(cn, sqlReplicaLag), nil).Scan(&sec); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(sec * float64(time.Second)), nil
+}
+
+func (r *replicas) close() {
+	if r == nil {
+		return
+	}
+
+	if r.cancel != nil {
+		r.cancel()
+		r.done.Wait()
+	}
+
+	for _, rp := range r.list {
+		rp.pool.Close()
+	}
+}
+
+// route chooses the pool of the dealer. Read only dealers go to replicas, when there are
+// healthy ones. Consistent dealer waits, until the replica replays the primary position
+func (c *conn) route(ctx context.Context, cfg *TxConfig) (*pgx.ConnPool, error) {
+	if cfg.AccessMode != pgx.ReadOnly {
+		return c.pool, nil
+	}
+
+	pool := c.replicas.pick()
+	if pool == nil || !cfg.Consistent {
+		if pool == nil {
+			pool = c.pool
+		}
+		return pool, nil
+	}
+
+	if err := c.catchup(ctx, pool); err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return c.pool, nil
+	}
+
+	return pool, nil
+}
+
+// catchup waits, until the replica replays the current position of the primary
+// It gives up after the max lag, so the primary is used then
+func (c *conn) catchup(ctx context.Context, pool *pgx.ConnPool) (err error) {
+	var lsn string
+	var done bool
+
+	if err = queryWal(ctx, c.pool, &lsn, "SELECT pg_current_wal_lsn()::text;"); err != nil {
+		return errors.Wrap(err, "getting primary position")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.replicas.maxLag)
+	defer cancel()
+
+	for delay := catchupMinDelay; ; {
+		err = queryWal(ctx, pool, &done, "SELECT pg_last_wal_replay_lsn() >= $1::pg_lsn;", lsn)
+
+		if err != nil || done {
+			return errors.Wrap(err, "waiting replica position")
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "waiting replica position")
+		case <-time.After(delay):
+		}
+
+		if delay *= 2; delay > catchupMaxDelay {
+			delay = catchupMaxDelay
+		}
+	}
+}
+
+// queryWal scans one value of the query with wal functions on a connection of the pool
+func queryWal(ctx context.Context, pool *pgx.ConnPool, dest interface{}, query string, args ...interface{}) error {
+	cn, err := pool.AcquireEx(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Release(cn)
+
+	return cn.QueryRowEx(ctx, walQuery(cn, query), nil, args...).Scan(dest)
+}
+
+// walQuery renames wal functions into the xlog ones, when the server is older than 10
+func walQuery(cn *pgx.Conn, query string) string {
+	version := strings.SplitN(cn.RuntimeParams["server_version"], ".", 2)[0]
+
+	if major, err := strconv.Atoi(version); err == nil && major < 10 {
+		return strings.NewReplacer("_wal_", "_xlog_", "_lsn(", "_location(").Replace(query)
+	}
+
+	return query
+}
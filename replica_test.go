@@ -0,0 +1,82 @@
+package wpgx_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+// replicaStr is the same server with another application name
+func replicaStr() string {
+	if !strings.HasPrefix(connStr, "postgres") {
+		return connStr + " application_name=wpgx_replica"
+	}
+	if strings.Contains(connStr, "?") {
+		return connStr + "&application_name=wpgx_replica"
+	}
+	return connStr + "?application_name=wpgx_replica"
+}
+
+func TestReplica(t *testing.T) {
+	db, err := wpgx.Connect(connStr, wpgx.Replica(replicaStr()), wpgx.ReplicaLag(time.Second, 100*time.Millisecond))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	const sqlApp = `SELECT current_setting('application_name') AS val;`
+
+	app := func(d wpgx.Dealer) string {
+		var name string
+		assert.NoError(t, d.Load(wpgx.Shape(&struct {
+			Name *string `db:"val"`
+		}{&name}), sqlApp))
+		return name
+	}
+
+	assert.NotEqual(t, "wpgx_replica", app(db))
+
+	d, err := db.NewDealer(wpgx.ReadOnly(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "wpgx_replica", app(d))
+	assert.NoError(t, d.Jail(true))
+
+	// Primary is not in recovery, so it has no replay position to wait for
+	d, err = db.NewDealer(wpgx.ReadOnly(true), wpgx.ReadYourWrites(true))
+	assert.NoError(t, err)
+	assert.NotEqual(t, "wpgx_replica", app(d))
+	assert.NoError(t, d.Jail(true))
+
+	ctx := wpgx.ReadOnlyContext(context.Background())
+
+	var name string
+	err = db.LoadContext(ctx, wpgx.Shape(&struct {
+		Name *string `db:"val"`
+	}{&name}), sqlApp)
+	assert.NoError(t, err)
+	assert.Equal(t, "wpgx_replica", name)
+
+	err = db.InTxContext(ctx, func(d wpgx.Dealer) error {
+		assert.Equal(t, "wpgx_replica", app(d))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Save goes to the primary anyway
+	err = db.Deal(nil, `CREATE TABLE replica_items (id int PRIMARY KEY);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE replica_items;`)
+		assert.NoError(t, err)
+	}()
+
+	sqlInsert, err := db.Cook(`INSERT INTO replica_items (id) VALUES (:id);`)
+	assert.NoError(t, err)
+
+	item := struct {
+		ID int `db:"id"`
+	}{1}
+	assert.NoError(t, db.SaveContext(ctx, wpgx.Shape(&item), sqlInsert, nil))
+}
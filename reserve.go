@@ -0,0 +1,399 @@
+package wpgx
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// ArchiveDir is a reserve subfolder for successfully replayed data
+const ArchiveDir = "archive"
+
+// reserveArg is a query argument with its type, so it can be restored as is
+type reserveArg struct {
+	Name  string          `json:"name"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// reserve saves data of the failed query as a json file next to the query text
+// Data of one row is a list of arguments, data of many rows is a list of lists
+func (c *conn) reserve(key string, cols []string, rows ...[]interface{}) {
+	if c.reservePath == "" || len(rows) == 0 {
+		return
+	}
+
+	const emsg = "reserving data"
+
+	var err error
+	var text []byte
+
+	dump := make([][]reserveArg, len(rows))
+
+	for i := range rows {
+		dump[i] = make([]reserveArg, len(cols))
+		for j := range cols {
+			if dump[i][j], err = dumpArg(cols[j], rows[i][j]); err != nil {
+				c.log(pgx.LogLevelError, emsg, Field{"error", err}, Field{"key", key}, Field{"rows", len(rows)})
+				return
+			}
+		}
+	}
+
+	if len(dump) == 1 {
+		text, err = json.MarshalIndent(dump[0], "", "  ")
+	} else {
+		text, err = json.MarshalIndent(dump, "", "  ")
+	}
+
+	if err != nil {
+		c.log(pgx.LogLevelError, emsg, Field{"error", err}, Field{"key", key}, Field{"rows", len(rows)})
+		return
+	}
+
+	sum := sha1.Sum(text)
+	hash := hex.EncodeToString(sum[:])
+	path := filepath.Join(c.reservePath, key+"_"+hash+".json")
+
+	if err = ioutil.WriteFile(path, text, 0755); err != nil {
+		c.log(pgx.LogLevelError, emsg, Field{"error", err}, Field{"key", key}, Field{"rows", len(rows)})
+	}
+}
+
+// reserveQuery saves the query text, so the dumps of the key can be replayed
+func (c *conn) reserveQuery(key, text string) error {
+	if c.reservePath == "" {
+		return nil
+	}
+
+	path := filepath.Join(c.reservePath, key+".pgsql")
+	return ioutil.WriteFile(path, []byte(text), 0755)
+}
+
+// dumpArg gets a driver value of the argument and marks it with a type
+func dumpArg(name string, arg interface{}) (dump reserveArg, err error) {
+	dump.Name = name
+
+	if valuer, ok := arg.(driver.Valuer); ok {
+		if v := reflect.ValueOf(arg); v.Kind() == reflect.Ptr && v.IsNil() {
+			arg = nil
+		} else if arg, err = valuer.Value(); err != nil {
+			return dump, errors.Wrap(err, "getting value of "+name)
+		}
+	}
+
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Ptr || !v.IsValid() {
+		dump.Type = "null"
+		dump.Value = json.RawMessage("null")
+		return dump, nil
+	}
+
+	arg = v.Interface()
+
+	switch v.Kind() {
+	case reflect.Bool:
+		dump.Type = "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		dump.Type = "int"
+	case reflect.Float32, reflect.Float64:
+		dump.Type = "float"
+	case reflect.String:
+		dump.Type = "string"
+		arg = v.String()
+	default:
+		switch arg.(type) {
+		case []byte:
+			dump.Type = "bytes"
+		case time.Time:
+			dump.Type = "time"
+		default:
+			// Postgres parses the text form into the type of the parameter
+			if arg, err = textOf(v); err != nil {
+				return dump, errors.Wrap(err, "getting text of "+name)
+			}
+			dump.Type = "text"
+		}
+	}
+
+	dump.Value, err = json.Marshal(arg)
+	return dump, errors.Wrap(err, "marshalling value of "+name)
+}
+
+// textOf makes the Postgres text form of the value. Byte arrays of 16 are UUIDs,
+// other slices and arrays are Postgres arrays and the rest is JSON
+func textOf(v reflect.Value) (string, error) {
+	if _, ok := v.Interface().(json.Marshaler); !ok {
+		if v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 && v.Len() == 16 {
+			b := make([]byte, 16)
+			reflect.Copy(reflect.ValueOf(b), v)
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+		}
+
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			return arrayText(v)
+		}
+	}
+
+	text, err := json.Marshal(v.Interface())
+	return string(text), err
+}
+
+// arrayText makes the Postgres array literal with quoted items
+func arrayText(v reflect.Value) (string, error) {
+	var b strings.Builder
+
+	b.WriteByte('{')
+
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		dump, err := dumpArg("", v.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+
+		var text string
+
+		switch dump.Type {
+		case "null":
+			b.WriteString("NULL")
+			continue
+		case "bool", "int", "float":
+			b.Write(dump.Value)
+			continue
+		case "bytes":
+			var raw []byte
+			err = json.Unmarshal(dump.Value, &raw)
+			text = `\x` + hex.EncodeToString(raw)
+		default:
+			err = json.Unmarshal(dump.Value, &text)
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		// Nested array is a part of the literal as is
+		if dump.Type == "text" && strings.HasPrefix(text, "{") && isArray(v.Index(i)) {
+			b.WriteString(text)
+			continue
+		}
+
+		b.WriteByte('"')
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text))
+		b.WriteByte('"')
+	}
+
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+// isArray checks, that the item is a slice or array, but not bytes or UUID
+func isArray(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+
+	if _, ok := v.Interface().(json.Marshaler); ok {
+		return false
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		return v.Type().Elem().Kind() != reflect.Uint8
+	case reflect.Array:
+		return v.Type().Elem().Kind() != reflect.Uint8 || v.Len() != 16
+	}
+	return false
+}
+
+// restore makes an argument of the query from the typed dump
+func (a reserveArg) restore() (interface{}, error) {
+	var err error
+
+	switch a.Type {
+	case "null":
+		return nil, nil
+	case "bool":
+		var v bool
+		err = json.Unmarshal(a.Value, &v)
+		return v, err
+	case "int":
+		var v int64
+		err = json.Unmarshal(a.Value, &v)
+		return v, err
+	case "float":
+		var v float64
+		err = json.Unmarshal(a.Value, &v)
+		return v, err
+	case "string":
+		var v string
+		err = json.Unmarshal(a.Value, &v)
+		return v, err
+	case "bytes":
+		var v []byte
+		err = json.Unmarshal(a.Value, &v)
+		return v, err
+	case "time":
+		var v time.Time
+		err = json.Unmarshal(a.Value, &v)
+		return v, err
+	case "text":
+		var v string
+		err = json.Unmarshal(a.Value, &v)
+		return v, err
+	case "json":
+		// Dumps of the older versions keep JSON as is
+		return string(a.Value), nil
+	}
+	return nil, errors.New("unknown argument type: " + a.Type)
+}
+
+// readReserve reads columns and rows of arguments from the dump file
+func readReserve(path string) ([]string, [][]interface{}, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "reading dump")
+	}
+
+	var dump [][]reserveArg
+
+	if err = json.Unmarshal(text, &dump); err != nil {
+		var row []reserveArg
+
+		if err = json.Unmarshal(text, &row); err != nil {
+			return nil, nil, errors.Wrap(err, "parsing dump")
+		}
+
+		dump = [][]reserveArg{row}
+	}
+
+	rows := make([][]interface{}, len(dump))
+
+	for i := range dump {
+		rows[i] = make([]interface{}, len(dump[i]))
+		for j := range dump[i] {
+			if rows[i][j], err = dump[i][j].restore(); err != nil {
+				return nil, nil, errors.Wrap(err, "restoring "+dump[i][j].Name)
+			}
+		}
+	}
+
+	var cols []string
+
+	if len(dump) > 0 {
+		cols = make([]string, len(dump[0]))
+		for j := range dump[0] {
+			cols[j] = dump[0][j].Name
+		}
+	}
+
+	return cols, rows, nil
+}
+
+// Replay runs failed saves from the reserve path again. Each dump is matched with
+// its query text and replayed in a separate transaction. Replayed files are moved
+// into the archive subfolder, the failed ones are left in place
+func Replay(db Connector, path string) (int, error) {
+	return ReplayContext(context.Background(), db, path)
+}
+
+// ReplayContext is a Replay with a context
+func ReplayContext(ctx context.Context, db Connector, path string) (count int, err error) {
+	const emsg = "replaying reserve"
+
+	var dumps []string
+
+	if dumps, err = filepath.Glob(filepath.Join(path, "*_*.json")); err != nil {
+		return 0, errors.Wrap(err, emsg)
+	}
+
+	archive := filepath.Join(path, ArchiveDir)
+
+	if err = os.MkdirAll(archive, 0755); err != nil {
+		return 0, errors.Wrap(err, emsg)
+	}
+
+	fails := make([]string, 0, len(dumps))
+
+	for _, dump := range dumps {
+		name := filepath.Base(dump)
+		key := name[:strings.LastIndex(name, "_")]
+
+		if _, err = os.Stat(filepath.Join(path, key+".pgsql")); err != nil {
+			// Not a query dump, there is no text to replay it
+			continue
+		}
+
+		if err = replayDump(ctx, db, path, key, dump); err != nil {
+			fails = append(fails, name+": "+err.Error())
+			continue
+		}
+
+		if err = os.Rename(dump, filepath.Join(archive, name)); err != nil {
+			return count, errors.Wrap(err, emsg)
+		}
+
+		count++
+	}
+
+	if len(fails) > 0 {
+		return count, errors.Errorf("%s: %d failed: %s", emsg, len(fails), strings.Join(fails, "; "))
+	}
+
+	return count, nil
+}
+
+func replayDump(ctx context.Context, db Connector, path, key, dump string) (err error) {
+	var text []byte
+	var cols []string
+	var rows [][]interface{}
+
+	if text, err = ioutil.ReadFile(filepath.Join(path, key+".pgsql")); err != nil {
+		return errors.Wrap(err, "reading query")
+	}
+
+	if cols, rows, err = readReserve(dump); err != nil {
+		return err
+	}
+
+	return db.InTxContext(ctx, func(d Dealer) (err error) {
+		var name string
+
+		// Columns are the same as of the cooked statement, so its key keeps them
+		if name, err = d.CookContext(ctx, string(text), cols...); err != nil {
+			return err
+		}
+
+		for i := range rows {
+			if err = d.DealContext(ctx, nil, name, rows[i]...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,126 @@
+package wpgx_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplay(t *testing.T) {
+	path := t.TempDir()
+
+	db, err := wpgx.Connect(connStr, wpgx.ReservePath(path))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE replay_users (id int PRIMARY KEY, name text not null);`)
+	assert.NoError(t, err)
+
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE replay_users;`)
+		assert.NoError(t, err)
+	}()
+
+	sqlInsert, err := db.Cook(`INSERT INTO replay_users (id, name) VALUES ($1, $2);`, "id", "name")
+	assert.NoError(t, err)
+
+	err = db.Save(&user{ID: 1}, sqlInsert, nil)
+	assert.Error(t, err)
+
+	_, err = db.Copy(&userSource{users: []*user{{ID: 2, Name: "copy"}, {ID: 3}}}, "replay_users", sqlInsert)
+	assert.Error(t, err)
+
+	count, err := wpgx.Replay(db, path)
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+
+	err = db.Deal(nil, `ALTER TABLE replay_users ALTER COLUMN name DROP NOT NULL;`)
+	assert.NoError(t, err)
+
+	count, err = wpgx.Replay(db, path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Replay keeps the columns of the cooked statement
+	err = db.Save(&user{ID: 4, Name: "after"}, sqlInsert, nil)
+	assert.NoError(t, err)
+
+	names := make(wpgx.Strings, 0, 4)
+	err = db.Deal(&names, `SELECT coalesce(name, 'null') FROM replay_users ORDER BY id;`)
+	assert.NoError(t, err)
+	assert.Equal(t, wpgx.Strings{"null", "copy", "null", "after"}, names)
+
+	dumps, err := filepath.Glob(filepath.Join(path, "*.json"))
+	assert.NoError(t, err)
+	assert.Empty(t, dumps)
+
+	dumps, err = filepath.Glob(filepath.Join(path, wpgx.ArchiveDir, "*.json"))
+	assert.NoError(t, err)
+	assert.Len(t, dumps, 2)
+
+	text, err := ioutil.ReadFile(dumps[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(text), `"type": "int"`)
+}
+
+func TestReplayTypes(t *testing.T) {
+	path := t.TempDir()
+
+	db, err := wpgx.Connect(connStr, wpgx.ReservePath(path))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE replay_items (
+		id   uuid   PRIMARY KEY,
+		tags text[] NOT NULL,
+		nums int[][],
+		meta jsonb,
+		note text   NOT NULL
+	);`)
+	assert.NoError(t, err)
+
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE replay_items;`)
+		assert.NoError(t, err)
+	}()
+
+	sqlInsert, err := db.Cook(`INSERT INTO replay_items (id, tags, nums, meta, note) VALUES (:id, :tags, :nums, :meta, :note);`)
+	assert.NoError(t, err)
+
+	type meta struct {
+		Color string `json:"color"`
+	}
+
+	item := struct {
+		ID   [16]byte `db:"id"`
+		Tags []string `db:"tags"`
+		Nums [][]int  `db:"nums"`
+		Meta meta     `db:"meta"`
+		Note *string  `db:"note"`
+	}{
+		ID:   [16]byte{0x12, 0x34, 15: 0x01},
+		Tags: []string{`a "quoted"`, `back\slash`, "{braces}"},
+		Nums: [][]int{{1, 2}, {3, 4}},
+		Meta: meta{Color: "red"},
+	}
+
+	err = db.Save(wpgx.Shape(&item), sqlInsert, nil)
+	assert.Error(t, err)
+
+	err = db.Deal(nil, `ALTER TABLE replay_items ALTER COLUMN note DROP NOT NULL;`)
+	assert.NoError(t, err)
+
+	count, err := wpgx.Replay(db, path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	var text string
+	err = db.Load(wpgx.Shape(&struct {
+		Text *string `db:"val"`
+	}{&text}), `SELECT concat_ws(' ', id, tags, nums, meta) AS val FROM replay_items;`)
+	assert.NoError(t, err)
+	assert.Equal(t, `12340000-0000-0000-0000-000000000001 {"a \"quoted\"","back\\slash","{braces}"} {{1,2},{3,4}} {"color": "red"}`, text)
+}
@@ -0,0 +1,413 @@
+package wpgx
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// ErrNoShardKey occurs when a single shard is needed, but the context has no shard key
+var ErrNoShardKey = errors.New("no shard key")
+
+// ShardFunc chooses a shard of the key, it is an index from zero to count
+type ShardFunc func(key interface{}, count int) (int, error)
+
+// HashShard is a default shard function. It is FNV-1a hash of the key text
+func HashShard(key interface{}, count int) (int, error) {
+	h := fnv.New32a()
+	fmt.Fprint(h, key)
+	return int(h.Sum32() % uint32(count)), nil
+}
+
+type shardKey struct{}
+
+// ShardContext sets the shard key, so the router sends calls with the context to its shard
+func ShardContext(ctx context.Context, key interface{}) context.Context {
+	return context.WithValue(ctx, shardKey{}, key)
+}
+
+// router is a connector over the shards
+type router struct {
+	shards []Connector
+	choose ShardFunc
+}
+
+// NewRouter makes a connector, which routes calls to the shards by the context shard key
+//
+// Without the key Deal, Load and Fetch run on every shard one by one with the same result
+// Cook, Uncook, Cache, Build and Pager prepare statements on every shard even with the key,
+// so keys of the cooked statements are the same on all of them. Other calls need the key
+//
+// Router has no dealer of its own, so Nest fails. Nest the dealer of NewDealer instead
+//
+// Listen merges notifications of all shards. Close closes all of them
+func NewRouter(choose ShardFunc, shards ...Connector) (Connector, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("no shards")
+	}
+
+	if choose == nil {
+		choose = HashShard
+	}
+
+	return &router{shards: shards, choose: choose}, nil
+}
+
+// shard finds the connector of the context shard key, it is nil without the key
+func (r *router) shard(ctx context.Context) (Connector, error) {
+	key := ctx.Value(shardKey{})
+	if key == nil {
+		return nil, nil
+	}
+
+	n, err := r.choose(key, len(r.shards))
+	if err != nil {
+		return nil, errors.Wrap(err, "choosing shard")
+	}
+
+	if n < 0 || n >= len(r.shards) {
+		return nil, errors.New("shard index is out of range: " + strconv.Itoa(n))
+	}
+
+	return r.shards[n], nil
+}
+
+// single finds the connector, when the call needs just one
+func (r *router) single(ctx context.Context) (Connector, error) {
+	s, err := r.shard(ctx)
+	if err == nil && s == nil {
+		err = ErrNoShardKey
+	}
+	return s, err
+}
+
+// each runs the function on every shard one by one, until it fails
+func (r *router) each(fn func(Connector) error) error {
+	for i := range r.shards {
+		if err := fn(r.shards[i]); err != nil {
+			return errors.Wrap(err, "shard "+strconv.Itoa(i))
+		}
+	}
+	return nil
+}
+
+func (r *router) Cook(text string, cols ...string) (string, error) {
+	return r.CookContext(context.Background(), text, cols...)
+}
+
+func (r *router) Uncook(key string) error {
+	return r.UncookContext(context.Background(), key)
+}
+
+func (r *router) Deal(result Collector, query string, args ...interface{}) error {
+	return r.DealContext(context.Background(), result, query, args...)
+}
+
+func (r *router) Load(item Shaper, query string, args ...interface{}) error {
+	return r.LoadContext(context.Background(), item, query, args...)
+}
+
+func (r *router) Fetch(item Shaper, query string, args ...interface{}) error {
+	return r.FetchContext(context.Background(), item, query, args...)
+}
+
+func (r *router) Save(item Shaper, key string, result Collector) error {
+	return r.SaveContext(context.Background(), item, key, result)
+}
+
+func (r *router) Copy(source Provider, table, key string, cols ...string) (int, error) {
+	return r.CopyContext(context.Background(), source, table, key, cols...)
+}
+
+func (r *router) Notify(channel, payload string) error {
+	return r.NotifyContext(context.Background(), channel, payload)
+}
+
+func (r *router) Batch() Batch { return &routerBatch{r: r} }
+
+func (r *router) Declare(scroll bool, query string, args ...interface{}) (Cursor, error) {
+	return r.DeclareContext(context.Background(), scroll, query, args...)
+}
+
+func (r *router) Nest() (Dealer, error) {
+	return r.NestContext(context.Background())
+}
+
+func (r *router) Mode() pgx.TxOptions { return r.shards[0].Mode() }
+
+func (r *router) Jail(commit bool) error { return nil }
+
+func (r *router) CookContext(ctx context.Context, text string, cols ...string) (key string, err error) {
+	err = r.each(func(s Connector) (err error) {
+		key, err = s.CookContext(ctx, text, cols...)
+		return
+	})
+	return key, err
+}
+
+func (r *router) UncookContext(ctx context.Context, key string) error {
+	return r.each(func(s Connector) error {
+		return s.UncookContext(ctx, key)
+	})
+}
+
+func (r *router) DealContext(ctx context.Context, result Collector, query string, args ...interface{}) error {
+	s, err := r.shard(ctx)
+	if err != nil {
+		return err
+	}
+
+	if s != nil {
+		return s.DealContext(ctx, result, query, args...)
+	}
+
+	return r.each(func(s Connector) error {
+		return s.DealContext(ctx, result, query, args...)
+	})
+}
+
+func (r *router) LoadContext(ctx context.Context, item Shaper, query string, args ...interface{}) error {
+	s, err := r.shard(ctx)
+	if err != nil {
+		return err
+	}
+
+	if s != nil {
+		return s.LoadContext(ctx, item, query, args...)
+	}
+
+	// The first found item is loaded, other shards get no more items
+	return r.DealContext(ctx, &oneItem{item: item}, query, args...)
+}
+
+func (r *router) FetchContext(ctx context.Context, item Shaper, query string, args ...interface{}) (err error) {
+	var s Connector
+
+	if s, err = r.shard(ctx); err != nil {
+		return err
+	}
+
+	if s != nil {
+		return s.FetchContext(ctx, item, query, args...)
+	}
+
+	one := &oneItem{item: item}
+
+	if err = r.DealContext(ctx, one, query, args...); err != nil {
+		return err
+	}
+
+	if !one.done {
+		return errors.Wrap(ErrNotFound, "fetching item")
+	}
+
+	if one.more {
+		return errors.Wrap(ErrTooManyRows, "fetching item")
+	}
+
+	return nil
+}
+
+func (r *router) SaveContext(ctx context.Context, item Shaper, key string, result Collector) error {
+	s, err := r.single(ctx)
+	if err != nil {
+		return errors.Wrap(err, "saving item")
+	}
+	return s.SaveContext(ctx, item, key, result)
+}
+
+func (r *router) CopyContext(ctx context.Context, source Provider, table, key string, cols ...string) (int, error) {
+	s, err := r.single(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "copying items")
+	}
+	return s.CopyContext(ctx, source, table, key, cols...)
+}
+
+func (r *router) NotifyContext(ctx context.Context, channel, payload string) error {
+	s, err := r.single(ctx)
+	if err != nil {
+		return errors.Wrap(err, "sending notification")
+	}
+	return s.NotifyContext(ctx, channel, payload)
+}
+
+func (r *router) DeclareContext(ctx context.Context, scroll bool, query string, args ...interface{}) (Cursor, error) {
+	s, err := r.single(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "declaring cursor")
+	}
+	return s.DeclareContext(ctx, scroll, query, args...)
+}
+
+func (r *router) NestContext(ctx context.Context) (Dealer, error) {
+	return nil, errors.New("router can't nest dealers, nest the dealer of NewDealer instead")
+}
+
+func (r *router) JailContext(ctx context.Context, commit bool) error { return nil }
+
+func (r *router) NewDealer(options ...func(*TxConfig) error) (Dealer, error) {
+	return r.NewDealerContext(context.Background(), options...)
+}
+
+func (r *router) NewDealerContext(ctx context.Context, options ...func(*TxConfig) error) (Dealer, error) {
+	s, err := r.single(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating dealer")
+	}
+	return s.NewDealerContext(ctx, options...)
+}
+
+func (r *router) InTx(fn func(Dealer) error, options ...func(*TxConfig) error) error {
+	return r.InTxContext(context.Background(), fn, options...)
+}
+
+func (r *router) InTxContext(ctx context.Context, fn func(Dealer) error, options ...func(*TxConfig) error) error {
+	s, err := r.single(ctx)
+	if err != nil {
+		return errors.Wrap(err, "running transaction")
+	}
+	return s.InTxContext(ctx, fn, options...)
+}
+
+func (r *router) Build(table Table) (Crud, error) {
+	return r.BuildContext(context.Background(), table)
+}
+
+func (r *router) BuildContext(ctx context.Context, table Table) (crud Crud, err error) {
+	err = r.each(func(s Connector) (err error) {
+		crud, err = s.BuildContext(ctx, table)
+		return
+	})
+	return crud, err
+}
+
+func (r *router) Pager(query string, size int, keys ...string) (*Pager, error) {
+	return r.PagerContext(context.Background(), query, size, keys...)
+}
+
+// PagerContext cooks the pager statements on every shard, so the pager works with a dealer of any one
+func (r *router) PagerContext(ctx context.Context, query string, size int, keys ...string) (p *Pager, err error) {
+	err = r.each(func(s Connector) error {
+		sp, err := s.PagerContext(ctx, query, size, keys...)
+		if p == nil {
+			p = sp
+		}
+		return err
+	})
+	return p, err
+}
+
+func (r *router) Cache(key string) error {
+	return r.each(func(s Connector) error {
+		return s.Cache(key)
+	})
+}
+
+func (r *router) Listen(channel string) (Subscription, error) {
+	sub := &routerSub{notes: make(chan *pgx.Notification, 64), stop: make(chan struct{})}
+
+	err := r.each(func(s Connector) error {
+		one, err := s.Listen(channel)
+		if err == nil {
+			sub.subs = append(sub.subs, one)
+		}
+		return err
+	})
+
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	for i := range sub.subs {
+		sub.wg.Add(1)
+		go sub.forward(sub.subs[i])
+	}
+
+	return sub, nil
+}
+
+func (r *router) Close() {
+	for i := range r.shards {
+		r.shards[i].Close()
+	}
+}
+
+// routerSub merges notifications of all the shards
+type routerSub struct {
+	subs  []Subscription
+	notes chan *pgx.Notification
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+func (s *routerSub) forward(sub Subscription) {
+	defer s.wg.Done()
+
+	for note := range sub.Notifications() {
+		select {
+		case s.notes <- note:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *routerSub) Notifications() <-chan *pgx.Notification { return s.notes }
+
+func (s *routerSub) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+
+		for i := range s.subs {
+			s.subs[i].Close()
+		}
+
+		s.wg.Wait()
+		close(s.notes)
+	})
+	return nil
+}
+
+// routerBatch keeps operations, until the shard is known in Send
+type routerBatch struct {
+	r   *router
+	ops []func(Batch)
+}
+
+func (b *routerBatch) Deal(result Collector, query string, args ...interface{}) {
+	b.ops = append(b.ops, func(q Batch) { q.Deal(result, query, args...) })
+}
+
+func (b *routerBatch) Load(item Shaper, query string, args ...interface{}) {
+	b.ops = append(b.ops, func(q Batch) { q.Load(item, query, args...) })
+}
+
+func (b *routerBatch) Save(item Shaper, key string, result Collector) {
+	b.ops = append(b.ops, func(q Batch) { q.Save(item, key, result) })
+}
+
+func (b *routerBatch) Send() error {
+	return b.SendContext(context.Background())
+}
+
+func (b *routerBatch) SendContext(ctx context.Context) error {
+	s, err := b.r.single(ctx)
+	if err != nil {
+		return errors.Wrap(err, "sending batch")
+	}
+
+	q := s.Batch()
+	for i := range b.ops {
+		b.ops[i](q)
+	}
+
+	return q.SendContext(ctx)
+}
@@ -0,0 +1,82 @@
+package wpgx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashShard(t *testing.T) {
+	n, err := wpgx.HashShard("tenant", 4)
+	assert.NoError(t, err)
+	assert.True(t, n >= 0 && n < 4)
+
+	m, err := wpgx.HashShard("tenant", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, n, m)
+
+	_, err = wpgx.NewRouter(nil)
+	assert.EqualError(t, err, "no shards")
+}
+
+func TestRouter(t *testing.T) {
+	db1, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+
+	db2, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+
+	// Keys go to the shard by its number
+	db, err := wpgx.NewRouter(func(key interface{}, count int) (int, error) {
+		return key.(int) % count, nil
+	}, db1, db2)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	const sqlSelect = `SELECT $1::int AS val;`
+
+	key, err := db.Cook(sqlSelect)
+	assert.NoError(t, err)
+
+	type item struct {
+		Val int `db:"val"`
+	}
+
+	var list []item
+	assert.NoError(t, db.Deal(wpgx.Slice(&list), key, 1))
+	assert.Equal(t, []item{{1}, {1}}, list)
+
+	list = nil
+	assert.NoError(t, db.DealContext(wpgx.ShardContext(context.Background(), 1), wpgx.Slice(&list), key, 2))
+	assert.Equal(t, []item{{2}}, list)
+
+	var one item
+	assert.NoError(t, db.Load(wpgx.Shape(&one), key, 3))
+	assert.Equal(t, 3, one.Val)
+
+	err = db.Fetch(wpgx.Shape(&one), key, 3)
+	assert.Equal(t, wpgx.ErrTooManyRows, errors.Cause(err))
+
+	_, err = db.NewDealer()
+	assert.Equal(t, wpgx.ErrNoShardKey, errors.Cause(err))
+
+	err = db.InTxContext(wpgx.ShardContext(context.Background(), 0), func(d wpgx.Dealer) error {
+		return d.Load(wpgx.Shape(&one), key, 4)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, one.Val)
+
+	// Statements are cooked on every shard even with the key
+	next, err := db.CookContext(wpgx.ShardContext(context.Background(), 0), `SELECT $1::int + 1 AS val;`)
+	assert.NoError(t, err)
+
+	list = nil
+	assert.NoError(t, db.Deal(wpgx.Slice(&list), next, 1))
+	assert.Equal(t, []item{{2}, {2}}, list)
+
+	_, err = db.Nest()
+	assert.EqualError(t, err, "router can't nest dealers, nest the dealer of NewDealer instead")
+}
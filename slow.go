@@ -0,0 +1,103 @@
+package wpgx
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// explainTimeout limits the plan capture of the slow query
+const explainTimeout = 5 * time.Second
+
+// clock returns a function, which logs the query, when it took longer than the threshold
+func (t *tx) clock(query string, args []interface{}) func() {
+	c := t.c
+
+	if c == nil || c.slowQuery <= 0 {
+		return func() {}
+	}
+
+	start := time.Now()
+
+	return func() {
+		if took := time.Since(start); took >= c.slowQuery {
+			c.slow(t.pool, query, args, took)
+		}
+	}
+}
+
+// slow writes a warning with the redacted arguments of the query
+// The plan is captured on another connection of the same pool, primary or replica,
+// so the record is written later
+func (c *conn) slow(pool *pgx.ConnPool, query string, args []interface{}, took time.Duration) {
+	key, text := query, query
+	if st, ok := c.stmts.get(query); ok {
+		key, text = st.key, st.text
+	}
+
+	fields := []Field{
+		{"args", redact(args)},
+		{"duration", took},
+		{"key", key},
+		{"sql", text},
+	}
+
+	if !c.explain || pool == nil {
+		c.log(pgx.LogLevelWarn, "slow query", fields...)
+		return
+	}
+
+	// Arguments may point to the model, so values are taken right now
+	values := make([]interface{}, len(args))
+
+	for i := range args {
+		dump, err := dumpArg("", args[i])
+		if err == nil {
+			values[i], err = dump.restore()
+		}
+		if err != nil {
+			fields = withField(fields, Field{"explain", errors.Wrap(err, "copying arguments")})
+			c.log(pgx.LogLevelWarn, "slow query", fields...)
+			return
+		}
+	}
+
+	go func() {
+		plan, err := explain(pool, text, values)
+		if err != nil {
+			fields = withField(fields, Field{"explain", err})
+		} else {
+			fields = withField(fields, Field{"plan", plan})
+		}
+		c.log(pgx.LogLevelWarn, "slow query", fields...)
+	}()
+}
+
+// explain gets the plan of the query in JSON format without running it
+func explain(pool *pgx.ConnPool, text string, args []interface{}) (plan string, err error) {
+	const emsg = "explaining query"
+
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+
+	cn, err := pool.AcquireEx(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, emsg)
+	}
+	defer pool.Release(cn)
+
+	err = cn.QueryRowEx(ctx, "EXPLAIN (FORMAT JSON) "+text, nil, args...).Scan(&plan)
+	return plan, errors.Wrap(err, emsg)
+}
+
+// withField inserts the field, so the fields are still sorted by key
+func withField(fields []Field, f Field) []Field {
+	i := sort.Search(len(fields), func(i int) bool { return fields[i].Key >= f.Key })
+	fields = append(fields, Field{})
+	copy(fields[i+1:], fields[i:])
+	fields[i] = f
+	return fields
+}
@@ -0,0 +1,157 @@
+package wpgx
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"sync"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// statement is a cooked query text with columns for Save and Copy
+// It is not changed after registration, so it is safe to use without the lock
+type statement struct {
+	key  string
+	text string
+	cols []string
+}
+
+// registry keeps cooked statements in the least recently used order
+// Statements are prepared lazily on each connection, that runs them
+// Evicted and uncooked ones are deallocated before next use of the connection
+type registry struct {
+	sync.Mutex
+	limit    int
+	order    *list.List
+	items    map[string]*list.Element
+	prepared map[*pgx.Conn]map[string]struct{}
+	stale    map[*pgx.Conn][]string
+}
+
+func newRegistry(limit int) *registry {
+	r := &registry{limit: limit}
+	r.reset()
+	return r
+}
+
+// reset forgets all statements, e.g. when connections are closed
+func (r *registry) reset() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.order = list.New()
+	r.items = make(map[string]*list.Element, 128)
+	r.prepared = make(map[*pgx.Conn]map[string]struct{})
+	r.stale = make(map[*pgx.Conn][]string)
+}
+
+// add registers the statement and evicts the oldest ones over the limit
+func (r *registry) add(key, text string, cols []string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if elem, ok := r.items[key]; ok {
+		elem.Value = &statement{key: key, text: text, cols: cols}
+		r.order.MoveToFront(elem)
+		return
+	}
+
+	r.items[key] = r.order.PushFront(&statement{key: key, text: text, cols: cols})
+
+	for r.limit > 0 && r.order.Len() > r.limit {
+		r.forget(r.order.Back().Value.(*statement).key)
+	}
+}
+
+// get finds the statement and marks it as recently used
+func (r *registry) get(key string) (*statement, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	elem, ok := r.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	r.order.MoveToFront(elem)
+	return elem.Value.(*statement), true
+}
+
+// remove forgets the statement, so it is deallocated on all connections
+func (r *registry) remove(key string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.items[key]; !ok {
+		return false
+	}
+
+	r.forget(key)
+	return true
+}
+
+func (r *registry) forget(key string) {
+	r.order.Remove(r.items[key])
+	delete(r.items, key)
+
+	for cn, names := range r.prepared {
+		if _, ok := names[key]; ok {
+			delete(names, key)
+			r.stale[cn] = append(r.stale[cn], key)
+		}
+	}
+}
+
+// mark remembers, that the statement is prepared on the connection
+func (r *registry) mark(cn *pgx.Conn, key string) {
+	r.Lock()
+	defer r.Unlock()
+
+	// Evicted while preparing, so it is stale already
+	if _, ok := r.items[key]; !ok {
+		r.stale[cn] = append(r.stale[cn], key)
+		return
+	}
+
+	names, ok := r.prepared[cn]
+	if !ok {
+		names = make(map[string]struct{}, len(r.items))
+		r.prepared[cn] = names
+	}
+	names[key] = struct{}{}
+}
+
+// drain returns statements, that should be deallocated on the connection
+// Dead connections are forgotten, the pool replaces them with new ones
+func (r *registry) drain(cn *pgx.Conn) []string {
+	r.Lock()
+	defer r.Unlock()
+
+	for dead := range r.prepared {
+		if !dead.IsAlive() {
+			delete(r.prepared, dead)
+			delete(r.stale, dead)
+		}
+	}
+
+	names := r.stale[cn]
+	delete(r.stale, cn)
+	return names
+}
+
+// unknown returns an error, when the query is a key of the statement, which is evicted or uncooked
+// Such a key is not a valid SQL, so it is not sent to the server as is
+func unknown(query string) error {
+	if len(query) != 2*sha1.Size {
+		return nil
+	}
+
+	for i := 0; i < len(query); i++ {
+		if ch := query[i]; !isDigit(ch) && (ch < 'a' || ch > 'f') {
+			return nil
+		}
+	}
+
+	return errors.New("unknown prepared query key: " + query)
+}
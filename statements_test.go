@@ -0,0 +1,65 @@
+package wpgx_test
+
+import (
+	"testing"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatements(t *testing.T) {
+	db, err := wpgx.Connect(connStr, wpgx.StatementLimit(2))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// Cooked on one connection, used on another
+	d1, err := db.NewDealer()
+	assert.NoError(t, err)
+
+	d2, err := db.NewDealer()
+	assert.NoError(t, err)
+
+	sqlOne, err := d1.Cook(`SELECT 1 AS id;`)
+	assert.NoError(t, err)
+
+	ints := make(wpgx.Ints, 0, 1)
+	assert.NoError(t, d2.Deal(&ints, sqlOne))
+	assert.Equal(t, wpgx.Ints{1}, ints)
+
+	assert.NoError(t, d1.Jail(true))
+	assert.NoError(t, d2.Jail(true))
+
+	// The oldest one is evicted over the limit
+	sqlTwo, err := db.Cook(`SELECT 2 AS id;`)
+	assert.NoError(t, err)
+
+	ints = ints[:0]
+	assert.NoError(t, db.Deal(&ints, sqlOne))
+	assert.Equal(t, wpgx.Ints{1}, ints)
+
+	sqlThree, err := db.Cook(`SELECT $1::int AS id;`, "id")
+	assert.NoError(t, err)
+
+	err = db.Save(new(user), sqlTwo, nil)
+	assert.EqualError(t, err, "unknown prepared query key: "+sqlTwo)
+
+	ints = ints[:0]
+	assert.NoError(t, db.Deal(&ints, sqlThree, 3))
+	assert.Equal(t, wpgx.Ints{3}, ints)
+
+	// Deallocated one is not sent as a text
+	assert.NoError(t, db.Uncook(sqlThree))
+
+	err = db.Uncook(sqlThree)
+	assert.EqualError(t, err, "unknown prepared query key: "+sqlThree)
+
+	err = db.Deal(&ints, sqlThree, 3)
+	assert.Contains(t, err.Error(), "unknown prepared query key: "+sqlThree)
+
+	err = db.Deal(&ints, sqlTwo)
+	assert.Contains(t, err.Error(), "unknown prepared query key: "+sqlTwo)
+
+	ints = ints[:0]
+	assert.NoError(t, db.Deal(&ints, sqlOne))
+	assert.Equal(t, wpgx.Ints{1}, ints)
+}
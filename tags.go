@@ -0,0 +1,147 @@
+package wpgx
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// TagName is a struct field tag, that describes a database column name
+const TagName = "db"
+
+// Tagged makes a Translator from a pointer to struct with `db:"column"` field tags
+// Fields without tag are named in lower case, fields with "-" tag are skipped
+// Embedded structs without tag are flattened
+func Tagged(ptr interface{}) Translator {
+	v := reflect.ValueOf(ptr)
+
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return new(tagged)
+	}
+
+	v = v.Elem()
+	return &tagged{val: v, fields: fieldsOf(v.Type())}
+}
+
+// Shape makes a Shaper from a pointer to tagged struct
+// It fills the struct right from the database, so no model is need
+func Shape(ptr interface{}) Shaper {
+	return &shape{Tagged(ptr).(*tagged)}
+}
+
+// Slice makes a Collector from a pointer to slice of tagged structs or pointers to them
+func Slice(ptr interface{}) Collector {
+	v := reflect.ValueOf(ptr)
+
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return new(slice)
+	}
+
+	s := &slice{val: v.Elem(), item: v.Elem().Type().Elem()}
+
+	if s.item.Kind() == reflect.Ptr {
+		s.item = s.item.Elem()
+		s.ptrs = true
+	}
+
+	if s.item.Kind() != reflect.Struct {
+		return new(slice)
+	}
+
+	return s
+}
+
+type fieldMap map[string][]int
+
+var fieldCache sync.Map
+
+func fieldsOf(typ reflect.Type) fieldMap {
+	if fields, ok := fieldCache.Load(typ); ok {
+		return fields.(fieldMap)
+	}
+
+	fields := make(fieldMap, typ.NumField())
+	collectFields(typ, nil, fields)
+	fieldCache.Store(typ, fields)
+	return fields
+}
+
+func collectFields(typ reflect.Type, index []int, fields fieldMap) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := field.Tag.Get(TagName)
+
+		if name == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+
+		path := make([]int, len(index), len(index)+1)
+		copy(path, index)
+		path = append(path, i)
+
+		if name == "" && field.Anonymous {
+			if field.Type.Kind() == reflect.Struct {
+				collectFields(field.Type, path, fields)
+			}
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		// Like in Go, the less nested field wins
+		if prev, ok := fields[name]; !ok || len(path) < len(prev) {
+			fields[name] = path
+		}
+	}
+}
+
+type tagged struct {
+	val    reflect.Value
+	fields fieldMap
+}
+
+func (t *tagged) Translate(name string) interface{} {
+	if path, ok := t.fields[name]; ok {
+		return t.val.FieldByIndex(path).Addr().Interface()
+	}
+	return nil
+}
+
+type shape struct{ *tagged }
+
+func (s *shape) Extrude() Translator { return s.tagged }
+func (s *shape) Receive(model Translator) error {
+	if model != s.tagged || s.fields == nil {
+		return ErrUnknownType
+	}
+	return nil
+}
+
+type slice struct {
+	val  reflect.Value
+	item reflect.Type
+	ptrs bool
+}
+
+func (s *slice) NewItem() Shaper {
+	if s.item == nil {
+		return Shape(nil)
+	}
+	return Shape(reflect.New(s.item).Interface())
+}
+
+func (s *slice) Collect(item Shaper) error {
+	model, ok := item.(*shape)
+	if !ok || model == nil || s.item == nil || model.val.Type() != s.item {
+		return ErrUnknownType
+	}
+
+	if s.ptrs {
+		s.val.Set(reflect.Append(s.val, model.val.Addr()))
+	} else {
+		s.val.Set(reflect.Append(s.val, model.val))
+	}
+	return nil
+}
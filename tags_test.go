@@ -0,0 +1,83 @@
+package wpgx_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+type tagBase struct {
+	ID int `db:"id"`
+}
+
+type tagUser struct {
+	tagBase
+	Name    sql.NullString `db:"name"`
+	Comment string         `db:"-"`
+	Age     int
+	hidden  int
+}
+
+func TestTags(t *testing.T) {
+	u := &tagUser{tagBase: tagBase{ID: 42}, Age: 7}
+	m := wpgx.Tagged(u)
+
+	assert.Equal(t, &u.ID, m.Translate("id"))
+	assert.Equal(t, &u.Name, m.Translate("name"))
+	assert.Equal(t, &u.Age, m.Translate("age"))
+	assert.Nil(t, m.Translate("comment"))
+	assert.Nil(t, m.Translate("hidden"))
+	assert.Nil(t, wpgx.Tagged(nil).Translate("id"))
+
+	s := wpgx.Shape(u)
+	assert.NoError(t, s.Receive(s.Extrude()))
+	assert.Equal(t, wpgx.ErrUnknownType, s.Receive(m))
+	assert.Equal(t, wpgx.ErrUnknownType, wpgx.Shape(42).Receive(nil))
+
+	var list []tagUser
+	assert.Equal(t, wpgx.ErrUnknownType, wpgx.Slice(&list).Collect(wpgx.Shape(&tagBase{})))
+	assert.Equal(t, wpgx.ErrUnknownType, wpgx.Slice(list).NewItem().Receive(nil))
+
+	db, err := wpgx.Connect(connStr)
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	defer db.Close()
+
+	err = db.Deal(nil, `CREATE TABLE tag_users (id serial PRIMARY KEY, name text, age int not null);`)
+	assert.NoError(t, err)
+	defer func() {
+		err = db.Deal(nil, `DROP TABLE tag_users;`)
+		assert.NoError(t, err)
+	}()
+
+	sqlInsert, err := db.Cook(`INSERT INTO tag_users (name, age) VALUES ($1, $2) RETURNING id;`, "name", "age")
+	assert.NoError(t, err)
+
+	items := []*tagUser{
+		{Name: sql.NullString{String: "first", Valid: true}, Age: 18},
+		{Age: 21},
+	}
+
+	for i := range items {
+		ids := make(wpgx.Ints, 0, 1)
+		err = db.Save(wpgx.Shape(items[i]), sqlInsert, &ids)
+		assert.NoError(t, err)
+		assert.Equal(t, wpgx.Ints{i + 1}, ids)
+	}
+
+	nu := new(tagUser)
+	err = db.Load(wpgx.Shape(nu), `SELECT * FROM tag_users WHERE id = $1;`, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, nu.ID)
+	assert.Equal(t, "first", nu.Name.String)
+	assert.Equal(t, 18, nu.Age)
+
+	err = db.Deal(wpgx.Slice(&list), `SELECT * FROM tag_users ORDER BY id;`)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, 2, list[1].ID)
+	assert.False(t, list[1].Name.Valid)
+	assert.Equal(t, 21, list[1].Age)
+}
@@ -0,0 +1,227 @@
+package wpgx
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// Tracer starts spans for distributed tracing. Dealer is a parent span and its
+// Cook, Deal, Load and Save calls are children with the statement attributes
+//
+// Package wpgx/otel has an adapter for OpenTelemetry, Recorder keeps spans in memory for tests
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced operation. Attributes follow OpenTelemetry conventions,
+// like db.system, db.statement and db.rows, and wpgx.key for the statement key
+type Span interface {
+	SetAttribute(key string, value interface{})
+	SetError(err error)
+	End()
+}
+
+// trace starts a child span of the dealer. Spans, started until the end, are its children
+// Rows are not set, when negative
+func (t *tx) trace(name, query string) func(rows int, err error) {
+	if t.c == nil || t.c.tracer == nil || t.tctx == nil {
+		return func(int, error) {}
+	}
+
+	parent := t.tctx
+	ctx, span := t.c.tracer.Start(parent, name)
+
+	text := query
+	if st, ok := t.c.stmts.get(query); ok {
+		span.SetAttribute("wpgx.key", st.key)
+		text = st.text
+	} else {
+		sum := sha1.Sum([]byte(query))
+		span.SetAttribute("wpgx.key", hex.EncodeToString(sum[:]))
+	}
+
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.statement", normalize(text))
+	t.tctx = ctx
+
+	return func(rows int, err error) {
+		t.tctx = parent
+
+		if rows >= 0 {
+			span.SetAttribute("db.rows", rows)
+		}
+		if err != nil {
+			span.SetError(err)
+		}
+		span.End()
+	}
+}
+
+// finish ends the transaction span
+func (t *tx) finish(commit bool, err error) {
+	if t.span == nil {
+		return
+	}
+
+	t.span.SetAttribute("wpgx.commit", commit)
+	if err != nil {
+		t.span.SetError(err)
+	}
+
+	t.span.End()
+	t.span = nil
+}
+
+// normalize replaces literals of the query with ? and squeezes spaces and comments,
+// so private values are not sent to the tracer
+func normalize(text string) string {
+	buf := make([]byte, 0, len(text))
+	space := false
+
+	for i := 0; i < len(text); {
+		ch := text[i]
+		next := byte(0)
+		if i+1 < len(text) {
+			next = text[i+1]
+		}
+
+		end := i + 1
+		lit := false
+
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			space = true
+			i++
+			continue
+		case ch == '-' && next == '-':
+			if end = i + strings.IndexByte(text[i:], '\n'); end <= i {
+				end = len(text)
+			}
+			space = true
+			i = end
+			continue
+		case ch == '/' && next == '*':
+			space = true
+			i = skipComment(text, i)
+			continue
+		case ch == '\'':
+			end, lit = skipQuoted(text, i, ch), true
+
+			// Prefix of the escaped string, like E'\n'
+			if n := len(buf); !space && n > 0 && (buf[n-1] == 'E' || buf[n-1] == 'e') && (n == 1 || !isIdent(buf[n-2], true)) {
+				buf = buf[:n-1]
+			}
+		case ch == '"':
+			end = skipQuoted(text, i, ch)
+		case ch == '$' && isDigit(next):
+			for end < len(text) && isDigit(text[end]) {
+				end++
+			}
+		case ch == '$':
+			if n, ok := skipDollar(text, i); ok {
+				end, lit = n, true
+			}
+		case isDigit(ch) && (space || len(buf) == 0 || !isIdent(buf[len(buf)-1], true)):
+			for end < len(text) && (isDigit(text[end]) || text[end] == '.') {
+				end++
+			}
+			lit = true
+		case isIdent(ch, true):
+			for end < len(text) && isIdent(text[end], true) {
+				end++
+			}
+		}
+
+		if space && len(buf) > 0 {
+			buf = append(buf, ' ')
+		}
+		space = false
+
+		if lit {
+			buf = append(buf, '?')
+		} else {
+			buf = append(buf, text[i:end]...)
+		}
+		i = end
+	}
+
+	return string(buf)
+}
+
+// Recorder is a tracer, which keeps all the spans in memory. It is handy for tests
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// RecordedSpan is a span of the Recorder. Parent is an index of the parent span, -1 for the root
+type RecordedSpan struct {
+	Name       string
+	Parent     int
+	Attributes map[string]interface{}
+	Err        error
+	Ended      bool
+
+	r  *Recorder
+	id int
+}
+
+type recorderKey struct{}
+
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &RecordedSpan{Name: name, Parent: -1, Attributes: make(map[string]interface{}), r: r, id: len(r.spans)}
+
+	if parent, ok := ctx.Value(recorderKey{}).(*RecordedSpan); ok && parent.r == r {
+		s.Parent = parent.id
+	}
+
+	r.spans = append(r.spans, s)
+	return context.WithValue(ctx, recorderKey{}, s), s
+}
+
+// Spans returns copies of the recorded spans in the order of start
+func (r *Recorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]RecordedSpan, len(r.spans))
+	for i, s := range r.spans {
+		list[i] = *s
+		list[i].Attributes = make(map[string]interface{}, len(s.Attributes))
+		for k, v := range s.Attributes {
+			list[i].Attributes[k] = v
+		}
+	}
+	return list
+}
+
+// Reset forgets all the recorded spans
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+func (s *RecordedSpan) SetAttribute(key string, value interface{}) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+func (s *RecordedSpan) SetError(err error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.Err = err
+}
+
+func (s *RecordedSpan) End() {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.Ended = true
+}
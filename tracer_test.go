@@ -0,0 +1,37 @@
+package wpgx_test
+
+import (
+	"testing"
+
+	"github.com/shestakovda/wpgx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	rec := new(wpgx.Recorder)
+
+	db, err := wpgx.Connect(connStr, wpgx.Trace(rec))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// Statement attribute is normalized, even when the query fails
+	statement := func(query string) interface{} {
+		rec.Reset()
+		db.Deal(nil, query)
+
+		for _, span := range rec.Spans() {
+			if span.Name == "wpgx.deal" {
+				return span.Attributes["db.statement"]
+			}
+		}
+		return nil
+	}
+
+	assert.Equal(t, `SELECT * FROM users WHERE id = $1 AND name = ?;`,
+		statement("SELECT *\n  FROM users\n WHERE id = $1 -- by id\n   AND name = 'Bob';"))
+
+	assert.Equal(t, `SELECT ?, ?, ?, ?, "col1" FROM t2 WHERE x IN (?, ?) /`,
+		statement(`SELECT 1.5, E'\'', $$ a $$, $x$ b $x$, "col1" /* c */ FROM t2 WHERE x IN (10, 20) /`))
+
+	assert.Equal(t, `SELECT arr[?:?], ?::jsonb`, statement(`SELECT arr[1:2], '{}'::jsonb`))
+}